@@ -0,0 +1,114 @@
+package contestcli
+
+import (
+	"fmt"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// runCommand runs the slash command of a comment on a pull request. Only collaborators with write access to the
+// repository may run commands and every command is acknowledged on its comment. It returns the webhook data of the head commit of the
+// pull request with the templates of the command, and false if no jobs are started for the command.
+func (p *webhookProcessor) runCommand(ctx xcontext.Context, webhookData WebhookData) (WebhookData, bool, error) {
+	command := *webhookData.command
+	reporter, err := clientapi.ActiveProviders().Reporter(webhookData.forge)
+	if err != nil {
+		return webhookData, false, err
+	}
+	commander, ok := reporter.(clientapi.Commander)
+	if !ok {
+		return webhookData, false, fmt.Errorf("the forge %s does not accept commands", webhookData.forge)
+	}
+	owner, repo, number := webhookData.repoOwner, webhookData.repoName, webhookData.prNumber
+
+	// Everybody can comment on public repositories, but only collaborators with write access may start jobs on the machines
+	collaborator, err := commander.HasWriteAccess(ctx, owner, repo, command.User)
+	if err != nil {
+		return webhookData, false, err
+	}
+	if !collaborator {
+		reply := fmt.Sprintf("@%s only collaborators with write access to %s/%s can run /%s", command.User, owner, repo, command.Name)
+		return webhookData, false, commander.Acknowledge(ctx, owner, repo, number, command, false, reply)
+	}
+
+	// Comments on the conversation of the pull request do not contain its head commit
+	if webhookData.headSHA == "" {
+		event, err := commander.PullRequest(ctx, owner, repo, number)
+		if err != nil {
+			return webhookData, false, err
+		}
+		event.DeliveryID, event.Command = webhookData.deliveryID, webhookData.command
+		webhookData = eventData(event)
+	}
+
+	switch command.Name {
+	case clientapi.CommandCancel:
+		jobs := p.branchJobs.cancel(webhookData.branch())
+		cancelJobs(ctx, p.transport, *p.cd.Flags.FlagRequestor, jobs, "Cancelled by @"+command.User)
+		var reply string
+		if len(jobs) == 0 {
+			reply = fmt.Sprintf("@%s no jobs are running for #%d", command.User, number)
+		}
+		return webhookData, false, commander.Acknowledge(ctx, owner, repo, number, command, true, reply)
+	case clientapi.CommandRetest:
+		webhookData.templates = p.failedTemplates(ctx, webhookData)
+		if len(webhookData.templates) == 0 {
+			reply := fmt.Sprintf("@%s no jobs failed for commit %s", command.User, shortSHA(webhookData.headSHA))
+			return webhookData, false, commander.Acknowledge(ctx, owner, repo, number, command, false, reply)
+		}
+	case clientapi.CommandRun:
+		template := command.Args[0]
+		if !p.cd.HasTemplate(template) {
+			reply := fmt.Sprintf("@%s the job template %s is not configured", command.User, template)
+			return webhookData, false, commander.Acknowledge(ctx, owner, repo, number, command, false, reply)
+		}
+		webhookData.templates = []string{template}
+	default:
+		return webhookData, false, fmt.Errorf("unknown command /%s", command.Name)
+	}
+	if err := commander.Acknowledge(ctx, owner, repo, number, command, true, ""); err != nil {
+		return webhookData, false, err
+	}
+	return webhookData, true, nil
+}
+
+// failedTemplates returns the templates whose latest jobs for the head commit finished without success.
+// The jobs of all matrix combinations of a template are started again if one of them failed.
+func (p *webhookProcessor) failedTemplates(ctx xcontext.Context, webhookData WebhookData) []string {
+	// Later jobs of the same name replace the ones they retested
+	latest := make(map[string]client.RunData)
+	var names []string
+	for _, jobData := range p.store.CommitJobs(webhookData.repoOwner, webhookData.repoName, webhookData.headSHA) {
+		// Jobs that were stored before their template was recorded can not be retested
+		if jobData.Template == "" {
+			continue
+		}
+		if _, found := latest[jobData.JobName]; !found {
+			names = append(names, jobData.JobName)
+		}
+		latest[jobData.JobName] = jobData
+	}
+
+	seen := make(map[string]bool)
+	var templates []string
+	for _, name := range names {
+		jobData := latest[name]
+		if seen[jobData.Template] {
+			continue
+		}
+		statusResp, err := client.JobStatus(ctx, p.transport, *p.cd.Flags.FlagRequestor, jobData.JobID)
+		if err != nil {
+			ctx.Warnf("could not retrieve the status of the job %d: %v", jobData.JobID, err)
+			continue
+		}
+		// Running jobs are not started twice
+		if !client.JobCompleted(statusResp.Data.Status) || client.JobSucceeded(statusResp.Data.Status) {
+			continue
+		}
+		seen[jobData.Template] = true
+		templates = append(templates, jobData.Template)
+	}
+	return templates
+}
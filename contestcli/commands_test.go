@@ -0,0 +1,86 @@
+package contestcli
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// fakeCommander accepts the commands of the collaborators with write access and records the acknowledgements
+type fakeCommander struct {
+	fakeReporter
+	collaborators map[string]bool
+	accepted      []bool
+	replies       []string
+}
+
+func (f *fakeCommander) HasWriteAccess(ctx context.Context, owner string, repo string, user string) (bool, error) {
+	return f.collaborators[user], nil
+}
+
+func (f *fakeCommander) PullRequest(ctx context.Context, owner string, repo string, number int) (*clientapi.Event, error) {
+	return &clientapi.Event{Forge: clientapi.ForgeGithub, Type: "pull_request", SHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		SSHURL: "git@github.com:jsmith/coreboot.git", Branch: "feature/spr", BaseBranch: "main", RepoOwner: owner, RepoName: repo, PRNumber: number}, nil
+}
+
+func (f *fakeCommander) Acknowledge(ctx context.Context, owner string, repo string, number int, command clientapi.Command, accepted bool, reply string) error {
+	f.accepted = append(f.accepted, accepted)
+	f.replies = append(f.replies, reply)
+	return nil
+}
+
+// Test for runCommand, if the commands of collaborators select the templates and every command is acknowledged
+func TestRunCommand(t *testing.T) {
+	requestor := "9e-contestcli"
+	cd := client.ClientDescriptor{
+		Flags:  client.Flags{FlagRequestor: &requestor},
+		Routes: []client.Route{{Templates: []string{"build.yaml", "archercity.yaml"}}},
+	}
+
+	tests := []struct {
+		name      string
+		command   clientapi.Command
+		start     bool
+		templates []string
+		accepted  bool
+		reply     string
+	}{
+		{"not a collaborator", clientapi.Command{Name: clientapi.CommandRun, Args: []string{"archercity.yaml"}, User: "mallory"}, false, nil,
+			false, "@mallory only collaborators with write access to 9elements/coreboot can run /run"},
+		{"run", clientapi.Command{Name: clientapi.CommandRun, Args: []string{"archercity.yaml"}, User: "jsmith"}, true, []string{"archercity.yaml"},
+			true, ""},
+		{"run unknown template", clientapi.Command{Name: clientapi.CommandRun, Args: []string{"../secrets.yaml"}, User: "jsmith"}, false, nil,
+			false, "@jsmith the job template ../secrets.yaml is not configured"},
+		{"cancel without jobs", clientapi.Command{Name: clientapi.CommandCancel, User: "jsmith"}, false, nil,
+			true, "@jsmith no jobs are running for #7"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commander := &fakeCommander{collaborators: map[string]bool{"jsmith": true}}
+			clientapi.SetProviders(&clientapi.Providers{Reporters: map[string]clientapi.StatusReporter{clientapi.ForgeGithub: commander}})
+			defer clientapi.SetProviders(nil)
+
+			p := &webhookProcessor{cd: cd, branchJobs: newBranchJobs()}
+			webhookData := WebhookData{forge: clientapi.ForgeGithub, deliveryID: "delivery-1", event: "pull_request",
+				repoOwner: "9elements", repoName: "coreboot", prNumber: 7, command: &test.command}
+			got, start, err := p.runCommand(xcontext.Background(), webhookData)
+			if err != nil {
+				t.Fatalf("runCommand failed: %v", err)
+			}
+			if start != test.start || !reflect.DeepEqual(got.templates, test.templates) {
+				t.Errorf("got %v, %v want %v, %v", start, got.templates, test.start, test.templates)
+			}
+			if !reflect.DeepEqual(commander.accepted, []bool{test.accepted}) || !reflect.DeepEqual(commander.replies, []string{test.reply}) {
+				t.Errorf("got %v, %q want %v, %q", commander.accepted, commander.replies, test.accepted, test.reply)
+			}
+			// The jobs are started for the head of the pull request, with the delivery of the comment
+			if test.start && (got.headSHA != "da1560886d4f094c3e6c9ef40349f7d38b5d27d7" || got.deliveryID != "delivery-1" || got.command == nil) {
+				t.Errorf("got %+v want the head of the pull request", got)
+			}
+		})
+	}
+}
@@ -2,15 +2,20 @@ package contestcli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/9elements/contest-client/pkg/client"
 	"github.com/9elements/contest-client/pkg/client/clientpluginregistry"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/9elements/contest-client/pkg/store"
 	"github.com/9elements/contest-client/plugins/clientplugins"
 	"github.com/facebookincubator/contest/pkg/logging"
+	"github.com/facebookincubator/contest/pkg/transport"
 	"github.com/facebookincubator/contest/pkg/transport/http"
 	"github.com/facebookincubator/contest/pkg/xcontext"
 	"github.com/facebookincubator/contest/pkg/xcontext/bundles/logrusctx"
@@ -19,16 +24,41 @@ import (
 	flag "github.com/spf13/pflag"
 )
 
+const (
+	// defaultWorkers is the number of webhooks that are processed concurrently if not configured
+	defaultWorkers = 4
+	// defaultStateFile is the file the state of the deliveries is stored in if not configured
+	defaultStateFile = "contestcli-state.json"
+	// webhookQueueSize is the number of webhooks that can wait for a free worker
+	webhookQueueSize = 100
+)
+
 // Define flags
 var (
-	flagSet    *flag.FlagSet
-	flagConfig *string
+	flagSet     *flag.FlagSet
+	flagConfig  *string
+	flagWait    *bool
+	flagSHA     *string
+	flagVars    *map[string]string
+	flagMatrix  *map[string]string
+	flagPayload *string
+	flagEvent   *string
+	flagStates  *[]string
+	flagTags    *[]string
 )
 
 // Init the flags
 func initFlags(cmd string) {
 	flagSet = flag.NewFlagSet(cmd, flag.ContinueOnError)
 	flagConfig = flagSet.StringP("config", "c", "clientconfig.json", "Path to the configuration file that describes the client")
+	flagWait = flagSet.BoolP("wait", "w", false, "After starting a job, wait for it to finish, and exit 0 only if it is successful")
+	flagSHA = flagSet.String("sha", "", "Commit SHA that is substituted into the job descriptor on start")
+	flagVars = flagSet.StringToString("var", map[string]string{}, "Template variables name=value that override the variables of the config file on start")
+	flagMatrix = flagSet.StringToString("matrix", map[string]string{}, "Matrix values axis=value of the job on start, render and validate")
+	flagPayload = flagSet.String("payload", "", "GitHub webhook payload whose values are substituted into the job descriptor (render and validate only)")
+	flagEvent = flagSet.String("event", "", "GitHub event type of the payload, e.g. push or pull_request, detected if empty")
+	flagStates = flagSet.StringSlice("states", []string{}, "List only jobs in the given states, e.g. JobStateFailed (list command only)")
+	flagTags = flagSet.StringSlice("tags", []string{}, "List only jobs with all the given tags (list command only)")
 
 	// Define flag usage
 	flagSet.Usage = func() {
@@ -38,14 +68,54 @@ func initFlags(cmd string) {
   contestcli [flags] command
 
 Commands:
+  serve
+        listen for webhooks and run the configured job templates for every event.
+        The listener is configured in the Listener section of the config file
+  start [--sha=SHA] [--var=name=value,...] [--matrix=axis=value,...] [file]
+        start a new job using the job descriptor passed via file or stdin.
+        If the file does not exist it is looked up in the descriptors/ directory
+  stop int
+        stop a job by job ID
+  status int
+        get the status of a job by job ID
+  retry int
+        retry a job by job ID
+  list [--states=JobStateFailed,...] [--tags=foo,bar]
+        list all jobs that match the given states and tags
   version
         request the API version to the server
+  render [--sha=SHA] [--payload=file] [--var=name=value,...] [--matrix=axis=value,...] [file]
+        print the job descriptor as it would be sent to the server, without a server
+  validate --sha=SHA|--payload=file [--var=name=value,...] file...
+        render the job descriptors and check them against the ConTest job descriptor
+        structure, without a server. Every matrix combination of the config is checked.
+        The plugins are checked against the Validation of the config file.
+        Exits non-zero if a descriptor is invalid
+
 Flags:
 `)
 		flagSet.PrintDefaults()
 	}
 }
 
+// loadConfig opens and decodes the config file
+func loadConfig(path string) (client.ClientDescriptor, error) {
+	var cd client.ClientDescriptor
+	// Open the configfile
+	configFile, err := os.Open(path)
+	if err != nil {
+		return cd, fmt.Errorf("unable to open the config file: %w", err)
+	}
+	defer configFile.Close()
+
+	// Parse and decode the json configfile
+	configDescription, _ := ioutil.ReadAll(configFile)
+	if err := json.Unmarshal(configDescription, &cd); err != nil {
+		return cd, fmt.Errorf("unable to decode the config file: %w", err)
+	}
+	return cd, nil
+}
+
 func CLIMain(cmd string, args []string, stdout io.Writer) error {
 	// Init the flags
 	initFlags(cmd)
@@ -56,18 +126,22 @@ func CLIMain(cmd string, args []string, stdout io.Writer) error {
 		return err
 	}
 
-	// Open the configfile
-	configFile, err := os.Open(*flagConfig)
-	if err != nil {
-		return fmt.Errorf("unable to open the config file: %w", err)
+	// Render and validate the job descriptors offline, the config file is optional for them
+	verb := strings.ToLower(flagSet.Arg(0))
+	if verb == "render" || verb == "validate" {
+		cd, err := loadConfig(*flagConfig)
+		if os.IsNotExist(errors.Unwrap(err)) {
+			cd, err = client.ClientDescriptor{}, nil
+		}
+		if err != nil {
+			return err
+		}
+		return runOfflineVerb(verb, cd, stdout)
 	}
-	defer configFile.Close()
 
-	// Parse and decode the json configfile
-	configDescription, _ := ioutil.ReadAll(configFile)
-	var cd client.ClientDescriptor
-	if err := json.Unmarshal(configDescription, &cd); err != nil {
-		return fmt.Errorf("unable to decode the config file: %w", err)
+	cd, err := loadConfig(*flagConfig)
+	if err != nil {
+		return err
 	}
 
 	// Setting defaults to empty config entries
@@ -80,6 +154,53 @@ func CLIMain(cmd string, args []string, stdout io.Writer) error {
 	if *cd.Flags.FlagLogLevel == "" {
 		*cd.Flags.FlagLogLevel = "debug"
 	}
+	if cd.Flags.FlagWorkers == nil || *cd.Flags.FlagWorkers < 1 {
+		workers := defaultWorkers
+		cd.Flags.FlagWorkers = &workers
+	}
+	if cd.Flags.FlagStateFile == nil || *cd.Flags.FlagStateFile == "" {
+		stateFile := defaultStateFile
+		cd.Flags.FlagStateFile = &stateFile
+	}
+	if cd.Listener.Addr == "" {
+		cd.Listener.Addr = "0.0.0.0:6000"
+	}
+	if cd.Listener.CertFile == "" {
+		cd.Listener.CertFile = "/certs/fullchain.crt"
+	}
+	if cd.Listener.KeyFile == "" {
+		cd.Listener.KeyFile = "/certs/server.key"
+	}
+	if cd.Listener.Path == "" {
+		cd.Listener.Path = "/"
+	}
+
+	// Authenticate as GitHub App if one is configured
+	if cd.GithubApp.AppID != 0 {
+		if err := clientapi.SetupGithubApp(cd.GithubApp.AppID, cd.GithubApp.PrivateKeyFile); err != nil {
+			return err
+		}
+	}
+
+	// Set the commit statuses on the self-hosted GitLab if one is configured
+	if cd.Gitlab.URL != "" {
+		clientapi.SetupGitlab(cd.Gitlab.URL)
+	}
+
+	// Vote on the patchsets of Gerrit if it is configured
+	if cd.Gerrit.URL != "" {
+		clientapi.SetupGerrit(cd.Gerrit.URL, cd.Gerrit.Label)
+	}
+
+	// Set the commit statuses of the repositories on Gitea
+	clientapi.SetupGitea(cd.Gitea)
+
+	// Activate the forges and notifiers of the config
+	providers, err := clientapi.NewProviders(cd)
+	if err != nil {
+		return err
+	}
+	clientapi.SetProviders(providers)
 
 	// Create logLevel
 	logLevel, err := logger.ParseLogLevel(*cd.Flags.FlagLogLevel)
@@ -95,54 +216,282 @@ func CLIMain(cmd string, args []string, stdout io.Writer) error {
 	clientPluginRegistry := clientpluginregistry.NewClientPluginRegistry(ctx)
 	clientplugins.Init(clientPluginRegistry, ctx.Logger())
 
-	// Creating a channel with a buffer size of 10, it's big enough
-	webhookData := make(chan WebhookData, 10)
+	// Create the transport to the ConTest server
+	transport := &http.HTTP{Addr: *cd.Flags.FlagAddr + *cd.Flags.FlagPortServer}
+
+	// Execute the command that was passed
+	switch verb {
+	case "":
+		return fmt.Errorf("missing command, see --help")
+	case "serve":
+		return serve(ctx, cd, clientPluginRegistry, transport, stdout)
+	default:
+		return runVerb(ctx, cd, transport, stdout)
+	}
+}
 
-	// Starting go routine to run a webhooklistener
-	go webhook(webhookData)
+// changedFiles requests the changed files of the pull request from its forge. Nil is returned if the forge
+// can not list them, then all routes match.
+func changedFiles(ctx xcontext.Context, webhookData WebhookData) []string {
+	reporter, err := clientapi.ActiveProviders().Reporter(webhookData.forge)
+	if err != nil {
+		return nil
+	}
+	lister, ok := reporter.(clientapi.ChangedFilesLister)
+	if !ok {
+		return nil
+	}
+	files, err := lister.ListPullRequestFiles(ctx, webhookData.repoOwner, webhookData.repoName, webhookData.prNumber, webhookData.headSHA)
+	if err != nil {
+		ctx.Errorf("%v", err)
+	}
+	return files
+}
 
-	// Iterate over every incoming webhook
-	for nextWebhookData := range webhookData {
-		// Iterate over all PreJobExecution plugins
-		for _, eh := range cd.PreJobExecutionHooks {
-			// Validate the current plugin
-			if err := eh.PreValidate(); err != nil {
-				return err
+// maxAttempts is the number of times a delivery is processed before a transient failure is treated as permanent
+const maxAttempts = 3
+
+// transientError marks failures that may go away if the delivery is processed again, e.g. an unreachable
+// server or a PostJobExecutionHook that could not reach its service. The delivery is resumed after a restart.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// webhookProcessor holds everything that is needed to process the incoming webhooks
+type webhookProcessor struct {
+	cd                   client.ClientDescriptor
+	clientPluginRegistry *clientpluginregistry.ClientPluginRegistry
+	transport            transport.Transport
+	stdout               io.Writer
+	branchJobs           *branchJobs
+	store                *store.Store
+}
+
+// serve starts the webhook listener and processes the incoming webhooks with a pool of workers
+func serve(ctx xcontext.Context, cd client.ClientDescriptor, clientPluginRegistry *clientpluginregistry.ClientPluginRegistry,
+	transport transport.Transport, stdout io.Writer) error {
+	// Open the state store that keeps track of the deliveries across restarts
+	store, err := store.Open(*cd.Flags.FlagStateFile)
+	if err != nil {
+		return fmt.Errorf("could not open the state store: %w", err)
+	}
+
+	processor := &webhookProcessor{
+		cd:                   cd,
+		clientPluginRegistry: clientPluginRegistry,
+		transport:            transport,
+		stdout:               stdout,
+		branchJobs:           newBranchJobs(),
+		store:                store,
+	}
+
+	// Creating a channel that queues the webhooks until a worker is free
+	webhookData := make(chan WebhookData, webhookQueueSize)
+
+	// Starting the workers, every worker processes one webhook at a time
+	for i := 0; i < *cd.Flags.FlagWorkers; i++ {
+		go func(worker int) {
+			for nextWebhookData := range webhookData {
+				ctx.Debugf("worker %d processes the webhook for commit %s", worker, nextWebhookData.headSHA)
+				if err := processor.process(ctx, nextWebhookData); err != nil {
+					ctx.Errorf("processing the webhook for commit %s failed: %v", nextWebhookData.headSHA, err)
+				}
 			}
-			// Register the current plugin
-			bundlePreExecutionHook, err := clientPluginRegistry.NewPreJobExecutionHookBundle(ctx, eh)
-			if err != nil {
-				return err
+		}(i)
+	}
+
+	// Resume the deliveries that were not finished before the last shutdown
+	go func() {
+		for _, delivery := range store.Pending() {
+			var pendingWebhookData WebhookData
+			if err := json.Unmarshal(delivery.Webhook, &pendingWebhookData); err != nil {
+				ctx.Errorf("could not decode the pending delivery %s: %v", delivery.ID, err)
+				continue
 			}
-			// Run the plugin
-			if _, err = bundlePreExecutionHook.PreJobExecutionHooks.Run(ctx, bundlePreExecutionHook.Parameters, cd, &http.HTTP{Addr: *cd.Flags.FlagAddr + *cd.Flags.FlagPortServer}); err != nil {
-				return err
+			ctx.Infof("resuming the delivery %s for commit %s", delivery.ID, pendingWebhookData.headSHA)
+			webhookData <- pendingWebhookData
+		}
+	}()
+
+	// Run the webhooklistener, it only returns if the listener failed
+	if err := webhook(ctx, cd, clientapi.ActiveProviders(), webhookData, store); err != nil {
+		return fmt.Errorf("the webhook listener stopped: %w", err)
+	}
+	return nil
+}
+
+// process runs the PreJobExecutionHooks, starts the jobs and runs the PostJobExecutionHooks for a single webhook.
+// The progress is recorded in the state store. If the jobs of the delivery were already started before a restart,
+// only the stages of the pipeline that were not reached and the PostJobExecutionHooks that did not finish yet are run.
+func (p *webhookProcessor) process(ctx xcontext.Context, webhookData WebhookData) (err error) {
+	cd := p.cd
+	id := webhookData.deliveryID
+
+	delivery, found := p.store.Delivery(id)
+	if !found {
+		return fmt.Errorf("the delivery %s is not in the state store", id)
+	}
+	attempt, err := p.store.StartAttempt(id)
+	if err != nil {
+		return fmt.Errorf("could not store the attempt of the delivery %s: %w", id, err)
+	}
+
+	rundata := delivery.Jobs
+
+	// The delivery is finished after it was processed or failed permanently. Deliveries that failed
+	// transiently or were interrupted are resumed after the next restart.
+	defer func() {
+		var transient *transientError
+		if err != nil && (errors.As(err, &transient) || ctx.Err() != nil) && attempt < maxAttempts {
+			ctx.Warnf("the delivery %s failed in attempt %d of %d, it is resumed after a restart", id, attempt, maxAttempts)
+			return
+		}
+		// Statuses that are collected per commit, e.g. Gerrit reviews, are posted even if a job did not report a final status
+		if len(rundata) > 0 {
+			contexts := make([]string, 0, len(rundata))
+			for _, jobData := range rundata {
+				contexts = append(contexts, jobData.JobName+". Test-Report:")
+			}
+			if finishErr := clientapi.ActiveProviders().FinishStatuses(ctx, webhookData.forge, webhookData.repoOwner,
+				webhookData.repoName, webhookData.headSHA, contexts); finishErr != nil {
+				ctx.Errorf("could not post the statuses of the delivery %s: %v", id, finishErr)
 			}
 		}
-		// Run the job and receive the rundata
-		var rundata []client.RunData
-		rundata, err := run(ctx, cd, &http.HTTP{Addr: *cd.Flags.FlagAddr + *cd.Flags.FlagPortServer}, stdout, nextWebhookData)
+		if doneErr := p.store.SetDone(id); doneErr != nil {
+			ctx.Errorf("could not mark the delivery %s as done: %v", id, doneErr)
+		}
+	}()
+	// Register the PostJobExecution plugins, the ones that implement client.JobStartHook also see every started job
+	postHooks := make([]*client.PostHookExecutionBundle, 0, len(cd.PostJobExecutionHooks))
+	for _, eh := range cd.PostJobExecutionHooks {
+		// Validate the current plugin
+		if err := eh.PostValidate(); err != nil {
+			return err
+		}
+		// Register the current plugin
+		bundlePostExecutionHook, err := p.clientPluginRegistry.NewPostJobExecutionHookBundle(ctx, eh)
 		if err != nil {
-			_ = fmt.Errorf("running the job failed (err: %w) You should probably check the connection and restart the test", err)
-			continue
+			return err
 		}
-		// Iterate over all PostJobExecution plugins
-		for _, eh := range cd.PostJobExecutionHooks {
-			// Validate the current plugin
-			if err := eh.PostValidate(); err != nil {
-				return err
-			}
-			// Register the current plugin
-			bundlePostExecutionHook, err := clientPluginRegistry.NewPostJobExecutionHookBundle(ctx, eh)
-			if err != nil {
+		postHooks = append(postHooks, bundlePostExecutionHook)
+	}
+	// Forget the jobs of the branch after the PostJobExecutionHooks are done
+	defer func() {
+		p.branchJobs.remove(webhookData.branch(), rundata)
+	}()
+	// The jobs that were started before a restart are not started again. The pipeline waits for them
+	// and starts the stages that were not reached, the PreJobExecutionHooks already ran for the webhook.
+	resumed := len(rundata) > 0
+	if resumed {
+		ctx.Infof("the jobs of the delivery %s were already started, resuming the pipeline", id)
+		superseded := p.branchJobs.add(webhookData.branch(), rundata)
+		cancelSuperseded(ctx, p.transport, *cd.Flags.FlagRequestor, superseded, p.branchJobs.head(webhookData.branch()))
+	}
+	// Commands of comments on pull requests are checked and acknowledged before any job is started.
+	// A resumed command was already acknowledged if its templates were recorded, it is not run twice.
+	if delivery.Templates != nil {
+		webhookData.templates = delivery.Templates
+	} else if webhookData.command != nil {
+		var start bool
+		var err error
+		webhookData, start, err = p.runCommand(ctx, webhookData)
+		if err != nil {
+			return fmt.Errorf("could not run the /%s command: %w", webhookData.command.Name, err)
+		}
+		if !start {
+			return nil
+		}
+		// Record the head commit and the templates of the command before the jobs are started
+		webhook, err := json.Marshal(webhookData)
+		if err != nil {
+			return fmt.Errorf("could not encode the webhook data: %w", err)
+		}
+		if err := p.store.SetWebhook(id, webhook, webhookData.templates); err != nil {
+			return fmt.Errorf("could not store the command: %w", err)
+		}
+	}
+	// Iterate over all PreJobExecution plugins, the ones that implement client.JobPreHook also see every job
+	var preHooks []*client.PreHookExecutionBundle
+	for _, eh := range cd.PreJobExecutionHooks {
+		// Validate the current plugin
+		if err := eh.PreValidate(); err != nil {
+			return err
+		}
+		// Register the current plugin
+		bundlePreExecutionHook, err := p.clientPluginRegistry.NewPreJobExecutionHookBundle(ctx, eh)
+		if err != nil {
+			return err
+		}
+		// Run the plugin, unless it already ran before the restart
+		if !resumed {
+			if _, err = bundlePreExecutionHook.PreJobExecutionHooks.Run(ctx, bundlePreExecutionHook.Parameters, cd, p.transport); err != nil {
 				return err
 			}
-			// Run the plugin
-			if _, err := bundlePostExecutionHook.PostJobExecutionHooks.Run(ctx, bundlePostExecutionHook.Parameters, cd, &http.HTTP{Addr: *cd.Flags.FlagAddr + *cd.Flags.FlagPortServer}, rundata); err != nil {
-				return err
+		}
+		preHooks = append(preHooks, bundlePreExecutionHook)
+	}
+	// The changed files of a pull request are not part of the webhook payload
+	if webhookData.templates == nil && webhookData.prNumber != 0 && webhookData.changedFiles == nil {
+		webhookData.changedFiles = changedFiles(ctx, webhookData)
+	}
+	// Cancel the jobs of older commits on the same branch to free the machines
+	if !resumed {
+		superseded := p.branchJobs.supersede(webhookData.branch(), webhookData.headSHA)
+		cancelSuperseded(ctx, p.transport, *cd.Flags.FlagRequestor, superseded, webhookData.headSHA)
+	}
+
+	// Remember the jobs of every pipeline stage as soon as they are started, so that they can be superseded
+	started := func(jobs []client.RunData) error {
+		// A newer commit of the branch may have been received while the jobs were started
+		superseded := p.branchJobs.add(webhookData.branch(), jobs)
+		cancelSuperseded(ctx, p.transport, *cd.Flags.FlagRequestor, superseded, p.branchJobs.head(webhookData.branch()))
+		rundata = append(rundata, jobs...)
+		if err := p.store.SetJobs(id, rundata); err != nil {
+			return fmt.Errorf("could not store the started jobs: %w", err)
+		}
+		return nil
+	}
+	// Record the templates of the pipeline, the stages that were not reached are started after a restart
+	if webhookData.templates == nil {
+		webhookData.templates = cd.JobTemplates(webhookData.clientEvent())
+	}
+	if err := p.store.SetTemplates(id, webhookData.templates); err != nil {
+		return fmt.Errorf("could not store the job templates: %w", err)
+	}
+	// Run the job pipeline, it returns after the last stage was started
+	stored := len(rundata)
+	if jobs, err := run(ctx, cd, p.transport, p.stdout, webhookData, preHooks, postHooks, delivery.Jobs, started); err != nil {
+		// The jobs that were started before the error are stored, the hooks of the resumed delivery wait for them
+		if len(jobs) > len(rundata)-stored {
+			if storeErr := started(jobs[len(rundata)-stored:]); storeErr != nil {
+				ctx.Errorf("%v", storeErr)
 			}
 		}
+		return fmt.Errorf("running the job failed (err: %w) You should probably check the connection and restart the test", err)
 	}
 
+	// Iterate over all PostJobExecution plugins
+	for i, eh := range cd.PostJobExecutionHooks {
+		// Skip the plugins that already finished before a restart
+		if delivery.HookDone(eh.Name) {
+			continue
+		}
+		bundlePostExecutionHook := postHooks[i]
+		// Run the plugin, a hook that did not finish is run again when the delivery is resumed
+		if _, err := bundlePostExecutionHook.PostJobExecutionHooks.Run(ctx, bundlePostExecutionHook.Parameters, cd, p.transport, rundata); err != nil {
+			return &transientError{fmt.Errorf("PostJobExecutionHook %s failed: %w", eh.Name, err)}
+		}
+		if err := p.store.SetHookDone(id, eh.Name); err != nil {
+			return fmt.Errorf("could not store the progress of the PostJobExecutionHooks: %w", err)
+		}
+	}
 	return nil
 }
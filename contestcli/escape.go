@@ -0,0 +1,282 @@
+package contestcli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Names of the escaper functions that are appended to the pipelines of the job templates
+const (
+	escapeDoubleQuoted = "_contest_escape_double_quoted"
+	escapeSingleQuoted = "_contest_escape_single_quoted"
+	escapeYAMLPlain    = "_contest_escape_yaml_plain"
+	escapeYAMLInline   = "_contest_escape_yaml_inline"
+)
+
+// formattingFuncs are the template functions that already format their result for the descriptor,
+// pipelines ending with one of them are not escaped
+var formattingFuncs = map[string]bool{
+	"toJson": true,
+	"toYaml": true,
+	"quote":  true,
+	"squote": true,
+	"raw":    true,
+}
+
+// escapeFuncs returns the escaper functions that are used by escapeTemplate
+func escapeFuncs() template.FuncMap {
+	return template.FuncMap{
+		escapeDoubleQuoted: func(value interface{}) string {
+			return jsonString(printValue(value), false)
+		},
+		escapeSingleQuoted: func(value interface{}) string {
+			return strings.ReplaceAll(printValue(value), "'", "''")
+		},
+		escapeYAMLPlain: func(value interface{}) string {
+			s := printValue(value)
+			// Numbers and booleans keep their type and missing values stay null, every other value is a string
+			if value == nil || isYAMLScalar(value) {
+				return s
+			}
+			if needsYAMLQuotes(s) {
+				return jsonString(s, true)
+			}
+			return s
+		},
+		escapeYAMLInline: func(value interface{}) (string, error) {
+			// Quotes would be part of the value in the middle of a plain scalar, only keep it on one line
+			s := strings.NewReplacer("\r", " ", "\n", " ").Replace(printValue(value))
+			if endsPlainScalar(s) {
+				return "", fmt.Errorf("%q can not be part of a plain YAML scalar, quote the scalar in the job template", s)
+			}
+			return s, nil
+		},
+	}
+}
+
+// escapeTemplate appends an escaper to every pipeline that prints a value into the job descriptor.
+// The escaper depends on the position of the value: inside a double quoted string the value is
+// escaped like a JSON string, which is valid in JSON and YAML, inside a single quoted YAML string
+// the single quotes are doubled. Plain YAML strings are quoted if they would not be read back as
+// the same string, values in the middle of a plain scalar are rejected if they would end it.
+// Values outside of strings in JSON descriptors are printed as they are, so that numbers and
+// booleans can be substituted.
+func escapeTemplate(tmpl *template.Template, YAML bool) {
+	if tmpl.Tree == nil {
+		return
+	}
+	e := &escaper{yaml: YAML, lineStart: true}
+	e.walk(tmpl.Tree.Root)
+}
+
+// escaper tracks the position in the descriptor while walking the template
+type escaper struct {
+	yaml bool
+
+	quote     byte // Quote of the current string, 0 outside of strings
+	escaped   bool // The last character was a backslash in a double quoted string
+	lineStart bool // Only indentation was printed since the start of the line
+	atValue   bool // A YAML value starts at the current position
+	indicator bool // The last character was a YAML indicator that starts a value if followed by a space
+}
+
+// walk escapes the pipelines of the node and its children
+func (e *escaper) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			e.walk(child)
+		}
+	case *parse.TextNode:
+		e.text(n.Text)
+	case *parse.ActionNode:
+		e.action(n)
+	case *parse.IfNode:
+		e.branches(&n.BranchNode)
+	case *parse.RangeNode:
+		e.branches(&n.BranchNode)
+	case *parse.WithNode:
+		e.branches(&n.BranchNode)
+	}
+}
+
+// branches walks both branches of a control structure starting at the same position.
+// The position after the structure is the one after the first branch.
+func (e *escaper) branches(n *parse.BranchNode) {
+	start := *e
+	e.walk(n.List)
+	end := *e
+	*e = start
+	e.walk(n.ElseList)
+	*e = end
+}
+
+// action appends the escaper for the current position to the pipeline of the action
+func (e *escaper) action(n *parse.ActionNode) {
+	pipe := n.Pipe
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return
+	}
+	// Pipelines that format their result themselves are not escaped
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && formattingFuncs[ident.Ident] {
+		return
+	}
+
+	var name string
+	switch {
+	case e.quote == '"':
+		name = escapeDoubleQuoted
+	case e.quote == '\'':
+		name = escapeSingleQuoted
+	case !e.yaml:
+		return
+	case e.lineStart || e.atValue:
+		name = escapeYAMLPlain
+	default:
+		name = escapeYAMLInline
+	}
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      n.Pos,
+		Args:     []parse.Node{parse.NewIdentifier(name).SetTree(nil).SetPos(n.Pos)},
+	})
+	// The printed value continues the scalar
+	e.lineStart, e.atValue, e.indicator = false, false, false
+}
+
+// text updates the position with the text of the template
+func (e *escaper) text(text []byte) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\n' {
+			// Strings in the descriptors do not span multiple lines
+			e.quote, e.escaped = 0, false
+			e.lineStart, e.atValue, e.indicator = true, false, false
+			continue
+		}
+
+		switch e.quote {
+		case '"':
+			switch {
+			case e.escaped:
+				e.escaped = false
+			case c == '\\':
+				e.escaped = true
+			case c == '"':
+				e.quote = 0
+			}
+			continue
+		case '\'':
+			if c == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+				} else {
+					e.quote = 0
+				}
+			}
+			continue
+		}
+
+		// Outside of strings
+		valueStart := e.lineStart || e.atValue
+		switch c {
+		case ' ', '\t':
+			if e.indicator {
+				e.atValue, e.indicator = true, false
+			}
+			continue
+		case '"':
+			if valueStart || !e.yaml {
+				e.quote = '"'
+			}
+		case '\'':
+			if valueStart && e.yaml {
+				e.quote = '\''
+			}
+		case '[', '{', ',':
+			e.lineStart, e.atValue, e.indicator = false, true, false
+			continue
+		case ':', '-', '?':
+			e.lineStart, e.atValue, e.indicator = false, false, true
+			continue
+		}
+		e.lineStart, e.atValue, e.indicator = false, false, false
+	}
+	// A value starts at the end of the text, e.g. "key:" directly followed by an action
+	if e.indicator && e.quote == 0 {
+		e.atValue = true
+	}
+}
+
+// printValue formats the value like text/template, but prints nothing for missing values
+func printValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonString escapes the string like a JSON string, the quotes are only kept if quoted is true
+func jsonString(s string, quoted bool) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail
+	_ = enc.Encode(s)
+	escaped := strings.TrimSuffix(buf.String(), "\n")
+	if quoted {
+		return escaped
+	}
+	return escaped[1 : len(escaped)-1]
+}
+
+// yamlKeywords are the plain YAML scalars that are resolved as booleans, null or special floats
+var yamlKeywords = map[string]bool{
+	"~": true, "null": true, "true": true, "false": true, "yes": true, "no": true, "y": true, "n": true,
+	"on": true, "off": true, ".inf": true, "-.inf": true, "+.inf": true, ".nan": true,
+}
+
+// isYAMLScalar returns true if the value is a number or a boolean, which are printed as plain scalars
+func isYAMLScalar(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// needsYAMLQuotes returns true if the string would not be read back as the same string from a plain YAML scalar,
+// because it changes the structure of the descriptor or is resolved as a number, a boolean or null
+func needsYAMLQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || yamlKeywords[strings.ToLower(s)] {
+		return true
+	}
+	// Anything that starts like a number may be resolved as one, e.g. an abbreviated commit SHA of digits or 1e5
+	if s[0] >= '0' && s[0] <= '9' {
+		return true
+	}
+	if len(s) > 1 && strings.ContainsAny(s[:1], "+.") && (s[1] == '.' || s[1] >= '0' && s[1] <= '9') {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.ContainsAny(s, ":#,[]{}\"'\n\r\t")
+}
+
+// endsPlainScalar returns true if the string would end the plain YAML scalar it is printed into,
+// with a mapping indicator or the start of a comment
+func endsPlainScalar(s string) bool {
+	return strings.Contains(s, ": ") || strings.Contains(s, ":\t") || strings.HasSuffix(s, ":") ||
+		strings.Contains(s, " #") || strings.Contains(s, "\t#") || strings.HasPrefix(s, "#")
+}
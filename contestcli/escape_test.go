@@ -0,0 +1,128 @@
+package contestcli
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the rendered job descriptors")
+
+// Test for ChangeJobDescriptor, if values with quotes, HTML characters and YAML indicators are
+// escaped for the descriptor. The rendered descriptors are compared to the golden files in testdata/render
+func TestRenderGolden(t *testing.T) {
+	webhookData := WebhookData{
+		headSHA:      "0123456789abcdef0123456789abcdef01234567",
+		refSHA:       `feature/a&b<c>"quoted"`,
+		ref:          `refs/heads/feature/a&b<c>"quoted"`,
+		title:        `Fix "escaping" & <html>: it's done # really`,
+		author:       "o'brien",
+		changedFiles: []string{"src/a b.c", `src/"quoted".h`},
+	}
+	vars := map[string]interface{}{"Runs": 2}
+
+	templates, err := filepath.Glob("testdata/render/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, templateFile := range templates {
+		if strings.HasSuffix(templateFile, ".golden") {
+			continue
+		}
+		t.Run(filepath.Base(templateFile), func(t *testing.T) {
+			data, err := ioutil.ReadFile(templateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			YAML := filepath.Ext(templateFile) == ".yaml"
+			got, err := ChangeJobDescriptor(data, webhookData, vars, YAML)
+			if err != nil {
+				t.Fatalf("ChangeJobDescriptor failed: %v", err)
+			}
+
+			// The rendered descriptor has to be valid
+			var body interface{}
+			if YAML {
+				err = yaml.Unmarshal(got, &body)
+			} else {
+				err = json.Unmarshal(got, &body)
+			}
+			if err != nil {
+				t.Errorf("rendered descriptor is invalid: %v\n%s", err, got)
+			}
+
+			goldenFile := templateFile + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// Test for the escaper of plain YAML values, if every string is read back as a string and numbers keep their type
+func TestEscapeYAMLPlain(t *testing.T) {
+	escape := escapeFuncs()[escapeYAMLPlain].(func(interface{}) string)
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"coreboot", "coreboot"},
+		{"feature/spr", "feature/spr"},
+		{"da15608", "da15608"},
+		{"0123456", `"0123456"`},
+		{"1e5", `"1e5"`},
+		{".5", `".5"`},
+		{"yes", `"yes"`},
+		{"Off", `"Off"`},
+		{"null", `"null"`},
+		{"~", `"~"`},
+		{"", `""`},
+		{"a: b", `"a: b"`},
+		{"fix # 1", `"fix # 1"`},
+		{2, "2"},
+		{true, "true"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := escape(test.value); got != test.want {
+			t.Errorf("got %s want %s for %#v", got, test.want, test.value)
+		}
+	}
+}
+
+// Test for the escaper of values in the middle of plain YAML scalars, if values that would end the scalar are rejected
+func TestEscapeYAMLInline(t *testing.T) {
+	escape := escapeFuncs()[escapeYAMLInline].(func(interface{}) (string, error))
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"feature/a&b", "feature/a&b", false},
+		{"line\nbreak", "line break", false},
+		{"soc/intel: fix", "", true},
+		{"fix # 1", "", true},
+		{"#1", "", true},
+		{"key:", "", true},
+	}
+	for _, test := range tests {
+		got, err := escape(test.value)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("got %q, %v want %q, error %t", got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package contestcli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// maxGerritBackoff limits the time between the reconnects of the Gerrit event stream
+const maxGerritBackoff = 5 * time.Minute
+
+// streamGerritEvents receives the patchset-created events with "gerrit stream-events" over SSH. The
+// authentication is done by the SSH agent or the keys of the user. The stream is reconnected until the
+// context is canceled.
+func (channel *Channel) streamGerritEvents(ctx xcontext.Context) {
+	backoff := time.Second
+	for {
+		connected := time.Now()
+		err := channel.readGerritStream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// Connections that were up for a while are not failing, the backoff starts over
+		if time.Since(connected) > maxGerritBackoff {
+			backoff = time.Second
+		}
+		ctx.Errorf("the gerrit event stream of %s stopped, reconnecting in %s: %v", channel.gerrit.SSH, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxGerritBackoff {
+			backoff = maxGerritBackoff
+		}
+	}
+}
+
+// readGerritStream runs "gerrit stream-events" and queues the events until the stream ends
+func (channel *Channel) readGerritStream(ctx xcontext.Context) error {
+	userHost, port, err := channel.gerrit.SSHAddress()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "ssh", "-p", port, "-o", "BatchMode=yes", "-o", "ServerAliveInterval=30",
+		userHost, "gerrit", "stream-events", "-s", "patchset-created")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start ssh: %w", err)
+	}
+	ctx.Infof("streaming the gerrit events of %s", channel.gerrit.SSH)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), clientapi.MaxGerritPayload)
+	for scanner.Scan() {
+		event, err := clientapi.ParseGerritEvent(channel.gerrit, scanner.Bytes())
+		if err != nil {
+			ctx.Errorf("could not parse gerrit event: %v", err)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		if _, err := channel.queue(eventData(event)); err != nil {
+			ctx.Errorf("could not queue the gerrit event of change %d: %v", event.PRNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
@@ -0,0 +1,99 @@
+package contestcli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/9elements/contest-client/pkg/store"
+)
+
+const gerritPatchSetPayload = `{
+  "type": "patchset-created",
+  "change": {
+    "project": "coreboot",
+    "branch": "main",
+    "number": 12345,
+    "subject": "mb/intel/adl: Add romstage",
+    "owner": {"username": "jsmith"}
+  },
+  "patchSet": {
+    "number": 3,
+    "revision": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+    "parents": ["95790bf891e76fee5e1747ab589903a6a1f80f22"],
+    "ref": "refs/changes/45/12345/3",
+    "kind": "%s",
+    "uploader": {"username": "adoe"}
+  }
+}`
+
+// Test for handleWebhook, if the patchset-created events are validated and mapped to the webhook data
+func TestGerritWebhook(t *testing.T) {
+	os.Setenv("GERRIT_SECRET", "gerrit-secret")
+	defer os.Unsetenv("GERRIT_SECRET")
+	gerrit := client.Gerrit{URL: "https://review.coreboot.org/", SSH: "contest@review.coreboot.org:29418", WebhookPath: "/gerrit"}
+
+	tests := []struct {
+		name    string
+		token   string
+		payload string
+		status  int
+		want    *WebhookData
+	}{
+		{"patchset", "gerrit-secret", strings.Replace(gerritPatchSetPayload, "%s", "REWORK", 1), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGerrit, deliveryID: "gerrit-12345-3", event: "pull_request",
+			headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", baseSHA: "95790bf891e76fee5e1747ab589903a6a1f80f22",
+			sshURL: "ssh://review.coreboot.org:29418/coreboot", cloneURL: "https://review.coreboot.org/coreboot",
+			baseSSHURL: "ssh://review.coreboot.org:29418/coreboot", baseCloneURL: "https://review.coreboot.org/coreboot",
+			refSHA: "refs/changes/45/12345", ref: "refs/changes/45/12345/3", baseRef: "main", repoName: "coreboot",
+			prNumber: 12345, patchSet: 3, title: "mb/intel/adl: Add romstage", author: "adoe",
+		}},
+		{"commit message only", "gerrit-secret", strings.Replace(gerritPatchSetPayload, "%s", "NO_CODE_CHANGE", 1), http.StatusOK, nil},
+		{"other event", "gerrit-secret", `{"type": "comment-added"}`, http.StatusOK, nil},
+		{"invalid token", "wrong", strings.Replace(gerritPatchSetPayload, "%s", "REWORK", 1), http.StatusUnauthorized, nil},
+		{"missing token", "", strings.Replace(gerritPatchSetPayload, "%s", "REWORK", 1), http.StatusUnauthorized, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := store.Open(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatalf("could not open the store: %v", err)
+			}
+			providers, err := clientapi.NewProviders(client.ClientDescriptor{Gerrit: gerrit})
+			if err != nil {
+				t.Fatalf("could not create the providers: %v", err)
+			}
+			channel := &Channel{webhookdata: make(chan WebhookData, 1), store: s, gerrit: gerrit, providers: providers}
+
+			req := httptest.NewRequest(http.MethodPost, "/gerrit?token="+test.token, strings.NewReader(test.payload))
+			rec := httptest.NewRecorder()
+			channel.handleWebhook(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("got status %d want %d", rec.Code, test.status)
+			}
+			select {
+			case got := <-channel.webhookdata:
+				if test.want == nil {
+					t.Fatalf("got %+v want no webhook", got)
+				}
+				if !reflect.DeepEqual(got, *test.want) {
+					t.Errorf("got %+v want %+v", got, *test.want)
+				}
+				if event := got.clientEvent(); event.Repository != "coreboot" || event.PatchSet != 3 {
+					t.Errorf("got %+v want repository coreboot and patchset 3", event)
+				}
+			default:
+				if test.want != nil {
+					t.Errorf("got no webhook want %+v", *test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package contestcli
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/9elements/contest-client/pkg/store"
+)
+
+const giteaPushPayload = `{
+  "ref": "refs/heads/main",
+  "before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "commits": [
+    {"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "message": "Add romstage\n", "added": ["src/mainboard/romstage.c"], "removed": [], "modified": ["Makefile.inc"]}
+  ],
+  "repository": {
+    "name": "coreboot", "full_name": "firmware/coreboot", "html_url": "https://git.example.com/firmware/coreboot",
+    "ssh_url": "git@git.example.com:firmware/coreboot.git", "clone_url": "https://git.example.com/firmware/coreboot.git"
+  },
+  "pusher": {"login": "jsmith"},
+  "sender": {"login": "jsmith"}
+}`
+
+const giteaPullRequestPayload = `{
+  "action": "%s",
+  "number": 7,
+  "pull_request": {
+    "number": 7,
+    "title": "Add romstage",
+    "user": {"login": "jsmith"},
+    "head": {"ref": "feature/spr", "sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+      "repo": {"ssh_url": "git@git.example.com:jsmith/coreboot.git", "clone_url": "https://git.example.com/jsmith/coreboot.git"}},
+    "base": {"ref": "main", "sha": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+      "repo": {"ssh_url": "git@git.example.com:firmware/coreboot.git", "clone_url": "https://git.example.com/firmware/coreboot.git"}}
+  },
+  "repository": {"name": "coreboot", "full_name": "firmware/coreboot", "html_url": "https://git.example.com/firmware/coreboot"}
+}`
+
+// Test for handleWebhook, if the Gitea webhooks are validated with the secret of the instance and mapped to the webhook data
+func TestGiteaWebhook(t *testing.T) {
+	os.Setenv("TEST_GITEA_SECRET", "gitea-secret")
+	defer os.Unsetenv("TEST_GITEA_SECRET")
+	instances := []client.Gitea{
+		{URL: "https://git.example.com", Repositories: []string{"firmware/*"}, SecretEnv: "TEST_GITEA_SECRET"},
+	}
+	sign := func(payload string, secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	pullRequestPayload := strings.Replace(giteaPullRequestPayload, "%s", "opened", 1)
+	synchronizedPayload := strings.Replace(giteaPullRequestPayload, "%s", "synchronized", 1)
+	editedPayload := strings.Replace(giteaPullRequestPayload, "%s", "edited", 1)
+
+	tests := []struct {
+		name      string
+		event     string
+		payload   string
+		signature string
+		status    int
+		want      *WebhookData
+	}{
+		{"push", "push", giteaPushPayload, sign(giteaPushPayload, "gitea-secret"), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitea, deliveryID: "gitea-firmware/coreboot-main-da1560886d4f094c3e6c9ef40349f7d38b5d27d7", event: "push",
+			headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", baseSHA: "95790bf891e76fee5e1747ab589903a6a1f80f22",
+			sshURL: "git@git.example.com:firmware/coreboot.git", cloneURL: "https://git.example.com/firmware/coreboot.git",
+			baseSSHURL: "git@git.example.com:firmware/coreboot.git", baseCloneURL: "https://git.example.com/firmware/coreboot.git",
+			refSHA: "main", ref: "refs/heads/main", baseRef: "main", repoOwner: "firmware", repoName: "coreboot",
+			title: "Add romstage", author: "jsmith", changedFiles: []string{"Makefile.inc", "src/mainboard/romstage.c"},
+		}},
+		{"pull request", "pull_request", pullRequestPayload, sign(pullRequestPayload, "gitea-secret"), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitea, deliveryID: "delivery-1", event: "pull_request",
+			headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", baseSHA: "95790bf891e76fee5e1747ab589903a6a1f80f22",
+			sshURL: "git@git.example.com:jsmith/coreboot.git", cloneURL: "https://git.example.com/jsmith/coreboot.git",
+			baseSSHURL: "git@git.example.com:firmware/coreboot.git", baseCloneURL: "https://git.example.com/firmware/coreboot.git",
+			refSHA: "feature/spr", ref: "refs/pull/7/head", baseRef: "main", repoOwner: "firmware", repoName: "coreboot",
+			prNumber: 7, title: "Add romstage", author: "jsmith",
+		}},
+		{"pull request new commits", "pull_request", synchronizedPayload, sign(synchronizedPayload, "gitea-secret"), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitea, deliveryID: "delivery-1", event: "pull_request",
+			headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", baseSHA: "95790bf891e76fee5e1747ab589903a6a1f80f22",
+			sshURL: "git@git.example.com:jsmith/coreboot.git", cloneURL: "https://git.example.com/jsmith/coreboot.git",
+			baseSSHURL: "git@git.example.com:firmware/coreboot.git", baseCloneURL: "https://git.example.com/firmware/coreboot.git",
+			refSHA: "feature/spr", ref: "refs/pull/7/head", baseRef: "main", repoOwner: "firmware", repoName: "coreboot",
+			prNumber: 7, title: "Add romstage", author: "jsmith",
+		}},
+		{"pull request edited", "pull_request", editedPayload, sign(editedPayload, "gitea-secret"), http.StatusOK, nil},
+		{"invalid signature", "push", giteaPushPayload, sign(giteaPushPayload, "wrong"), http.StatusUnauthorized, nil},
+		{"missing signature", "push", giteaPushPayload, "", http.StatusUnauthorized, nil},
+		{"unknown repository", "push", strings.Replace(giteaPushPayload, "firmware/coreboot", "jsmith/coreboot", -1),
+			sign(strings.Replace(giteaPushPayload, "firmware/coreboot", "jsmith/coreboot", -1), "gitea-secret"), http.StatusForbidden, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := store.Open(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatalf("could not open the store: %v", err)
+			}
+			providers, err := clientapi.NewProviders(client.ClientDescriptor{Gitea: instances})
+			if err != nil {
+				t.Fatalf("could not create the providers: %v", err)
+			}
+			channel := &Channel{webhookdata: make(chan WebhookData, 1), store: s, providers: providers}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.payload))
+			req.Header.Set("X-Gitea-Event", test.event)
+			req.Header.Set("X-GitHub-Event", test.event)
+			req.Header.Set("X-Gitea-Delivery", "delivery-1")
+			req.Header.Set("X-Gitea-Signature", test.signature)
+			rec := httptest.NewRecorder()
+			channel.handleWebhook(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("got status %d want %d", rec.Code, test.status)
+			}
+			select {
+			case got := <-channel.webhookdata:
+				if test.want == nil {
+					t.Fatalf("got %+v want no webhook", got)
+				}
+				if !reflect.DeepEqual(got, *test.want) {
+					t.Errorf("got %+v want %+v", got, *test.want)
+				}
+			default:
+				if test.want != nil {
+					t.Errorf("got no webhook want %+v", *test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package contestcli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/9elements/contest-client/pkg/store"
+)
+
+const gitlabPushPayload = `{
+  "object_kind": "push",
+  "before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "ref": "refs/heads/feature/spr",
+  "user_username": "jsmith",
+  "project": {
+    "path_with_namespace": "firmware/intel/coreboot",
+    "git_ssh_url": "git@gitlab.example.com:firmware/intel/coreboot.git",
+    "git_http_url": "https://gitlab.example.com/firmware/intel/coreboot.git"
+  },
+  "commits": [
+    {"id": "b6568db1bc1dcd7f8b4d5a946b0b91f9dacd7327", "message": "Update Makefile", "added": [], "modified": ["Makefile.inc"], "removed": []},
+    {"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "message": "Add romstage\n\nDetails", "added": ["src/mainboard/romstage.c"], "modified": ["Makefile.inc"], "removed": []}
+  ]
+}`
+
+const gitlabMergeRequestPayload = `{
+  "object_kind": "merge_request",
+  "user": {"username": "jsmith"},
+  "project": {"path_with_namespace": "firmware/coreboot"},
+  "object_attributes": {
+    "iid": 7,
+    "title": "Add romstage",
+    "action": "%s",
+    "oldrev": "%s",
+    "source_branch": "feature/spr",
+    "target_branch": "main",
+    "source": {"git_ssh_url": "git@gitlab.example.com:jsmith/coreboot.git", "git_http_url": "https://gitlab.example.com/jsmith/coreboot.git"},
+    "target": {"git_ssh_url": "git@gitlab.example.com:firmware/coreboot.git", "git_http_url": "https://gitlab.example.com/firmware/coreboot.git"},
+    "last_commit": {"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}
+  }
+}`
+
+// mergeRequest returns the payload of the merge request webhook with the action and the oldrev
+func mergeRequest(action string, oldRev string) string {
+	return strings.Replace(strings.Replace(gitlabMergeRequestPayload, "%s", action, 1), "%s", oldRev, 1)
+}
+
+// Test for handleWebhook, if the GitLab webhooks are validated and mapped to the webhook data
+func TestGitlabWebhook(t *testing.T) {
+	os.Setenv("GITLAB_SECRET", "gitlab-secret")
+	defer os.Unsetenv("GITLAB_SECRET")
+
+	tests := []struct {
+		name    string
+		event   string
+		token   string
+		payload string
+		status  int
+		want    *WebhookData
+	}{
+		{"push", "Push Hook", "gitlab-secret", gitlabPushPayload, http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitlab, deliveryID: "gitlab-firmware/intel/coreboot-feature/spr-da1560886d4f094c3e6c9ef40349f7d38b5d27d7", event: "push",
+			headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", baseSHA: "95790bf891e76fee5e1747ab589903a6a1f80f22",
+			sshURL: "git@gitlab.example.com:firmware/intel/coreboot.git", cloneURL: "https://gitlab.example.com/firmware/intel/coreboot.git",
+			baseSSHURL: "git@gitlab.example.com:firmware/intel/coreboot.git", baseCloneURL: "https://gitlab.example.com/firmware/intel/coreboot.git",
+			refSHA: "feature/spr", ref: "refs/heads/feature/spr", baseRef: "feature/spr", repoOwner: "firmware/intel", repoName: "coreboot",
+			title: "Add romstage", author: "jsmith", changedFiles: []string{"Makefile.inc", "src/mainboard/romstage.c"},
+		}},
+		{"merge request", "Merge Request Hook", "gitlab-secret", mergeRequest("open", ""), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitlab, deliveryID: "uuid-1", event: "pull_request", headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+			sshURL: "git@gitlab.example.com:jsmith/coreboot.git", cloneURL: "https://gitlab.example.com/jsmith/coreboot.git",
+			baseSSHURL: "git@gitlab.example.com:firmware/coreboot.git", baseCloneURL: "https://gitlab.example.com/firmware/coreboot.git",
+			refSHA: "feature/spr", ref: "refs/merge-requests/7/head", baseRef: "main", repoOwner: "firmware", repoName: "coreboot",
+			prNumber: 7, title: "Add romstage", author: "jsmith",
+		}},
+		{"merge request new commits", "Merge Request Hook", "gitlab-secret", mergeRequest("update", "95790bf891e76fee5e1747ab589903a6a1f80f22"), http.StatusAccepted, &WebhookData{
+			forge: clientapi.ForgeGitlab, deliveryID: "uuid-1", event: "pull_request", headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+			sshURL: "git@gitlab.example.com:jsmith/coreboot.git", cloneURL: "https://gitlab.example.com/jsmith/coreboot.git",
+			baseSSHURL: "git@gitlab.example.com:firmware/coreboot.git", baseCloneURL: "https://gitlab.example.com/firmware/coreboot.git",
+			refSHA: "feature/spr", ref: "refs/merge-requests/7/head", baseRef: "main", repoOwner: "firmware", repoName: "coreboot",
+			prNumber: 7, title: "Add romstage", author: "jsmith",
+		}},
+		{"merge request update", "Merge Request Hook", "gitlab-secret", mergeRequest("update", ""), http.StatusOK, nil},
+		{"invalid token", "Push Hook", "wrong", gitlabPushPayload, http.StatusUnauthorized, nil},
+		{"missing token", "Push Hook", "", gitlabPushPayload, http.StatusUnauthorized, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := store.Open(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatalf("could not open the store: %v", err)
+			}
+			providers, err := clientapi.NewProviders(client.ClientDescriptor{})
+			if err != nil {
+				t.Fatalf("could not create the providers: %v", err)
+			}
+			channel := &Channel{webhookdata: make(chan WebhookData, 1), store: s, providers: providers}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.payload))
+			req.Header.Set("X-Gitlab-Event", test.event)
+			req.Header.Set("X-Gitlab-Event-UUID", "uuid-1")
+			if test.token != "" {
+				req.Header.Set("X-Gitlab-Token", test.token)
+			}
+			rec := httptest.NewRecorder()
+			channel.handleWebhook(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("got status %d want %d", rec.Code, test.status)
+			}
+			select {
+			case got := <-channel.webhookdata:
+				if test.want == nil {
+					t.Fatalf("got %+v want no webhook", got)
+				}
+				if !reflect.DeepEqual(got, *test.want) {
+					t.Errorf("got %+v want %+v", got, *test.want)
+				}
+			default:
+				if test.want != nil {
+					t.Errorf("got no webhook want %+v", *test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package contestcli
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// lineMarker is the name of the function that records the line of the job template at the current output line
+const lineMarker = "_contest_line"
+
+// lineMap maps the lines of the rendered job descriptor to the lines of the job template.
+// Actions and control structures can add or remove lines, e.g. range loops or multi-line values.
+type lineMap struct {
+	out   *lineCounter
+	marks []lineMark
+}
+
+// lineMark records that the output line starts the text of the template line
+type lineMark struct {
+	out    int
+	source int
+}
+
+// lineCounter is the output of the template, it counts the lines that were written
+type lineCounter struct {
+	bytes.Buffer
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.lines += bytes.Count(p, []byte("\n"))
+	return c.Buffer.Write(p)
+}
+
+// markLines inserts a line marker before the text of every template line, so that the output lines can
+// be mapped back while the template is executed. It has to run after escapeTemplate, the markers print nothing.
+func markLines(tmpl *template.Template, source string) *lineMap {
+	lines := &lineMap{out: &lineCounter{}}
+	tmpl.Funcs(template.FuncMap{lineMarker: lines.mark})
+	if tmpl.Tree != nil {
+		markList(tmpl.Tree.Root, source)
+	}
+	return lines
+}
+
+// markList inserts the line markers into the list and the lists of its control structures
+func markList(list *parse.ListNode, source string) {
+	if list == nil {
+		return
+	}
+	var nodes []parse.Node
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			line := strings.Count(source[:n.Pos], "\n") + 1
+			text := n.Text
+			for len(text) > 0 {
+				end := bytes.IndexByte(text, '\n') + 1
+				if end == 0 {
+					end = len(text)
+				}
+				nodes = append(nodes, lineMarkerNode(n.Pos, line), &parse.TextNode{NodeType: parse.NodeText, Pos: n.Pos, Text: text[:end]})
+				text = text[end:]
+				line++
+			}
+			// The text that follows the last newline starts on the next line
+			if bytes.HasSuffix(n.Text, []byte("\n")) {
+				nodes = append(nodes, lineMarkerNode(n.Pos, line))
+			}
+			continue
+		case *parse.IfNode:
+			markList(n.List, source)
+			markList(n.ElseList, source)
+		case *parse.RangeNode:
+			markList(n.List, source)
+			markList(n.ElseList, source)
+		case *parse.WithNode:
+			markList(n.List, source)
+			markList(n.ElseList, source)
+		}
+		nodes = append(nodes, node)
+	}
+	list.Nodes = nodes
+}
+
+// lineMarkerNode returns an action that records the template line at the current output line
+func lineMarkerNode(pos parse.Pos, line int) parse.Node {
+	arg := &parse.NumberNode{NodeType: parse.NodeNumber, Pos: pos, IsInt: true, Int64: int64(line), Text: strconv.Itoa(line)}
+	return &parse.ActionNode{NodeType: parse.NodeAction, Pos: pos, Pipe: &parse.PipeNode{NodeType: parse.NodePipe, Pos: pos,
+		Cmds: []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: pos,
+			Args: []parse.Node{parse.NewIdentifier(lineMarker).SetTree(nil).SetPos(pos), arg}}}}}
+}
+
+// mark records the template line at the current output line, the first mark of an output line is kept
+func (m *lineMap) mark(line int) string {
+	out := m.out.lines + 1
+	if len(m.marks) == 0 || m.marks[len(m.marks)-1].out != out {
+		m.marks = append(m.marks, lineMark{out: out, source: line})
+	}
+	return ""
+}
+
+// source returns the template line of the output line, the lines of a multi-line value belong to the line of its action
+func (m *lineMap) source(out int) int {
+	line := out
+	for _, mark := range m.marks {
+		if mark.out > out {
+			break
+		}
+		line = mark.source
+	}
+	return line
+}
+
+// sourceError replaces the lines of the rendered descriptor in a DescriptorError with the lines of the job template
+func (m *lineMap) sourceError(err error) error {
+	var descErr *client.DescriptorError
+	if !errors.As(err, &descErr) {
+		return err
+	}
+	for i := range descErr.Problems {
+		if descErr.Problems[i].Line > 0 {
+			descErr.Problems[i].Line = m.source(descErr.Problems[i].Line)
+		}
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package contestcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/google/go-github/github"
+)
+
+// runOfflineVerb executes the commands that do not need a ConTest server, so that the job
+// descriptors can be checked e.g. in a pre-commit hook
+func runOfflineVerb(verb string, cd client.ClientDescriptor, stdout io.Writer) error {
+	webhookData, err := offlineWebhookData()
+	if err != nil {
+		return err
+	}
+
+	switch verb {
+	case "render":
+		jobDesc, _, err := renderJobFile(cd, flagSet.Arg(1), webhookData.withMatrix(flagMatrixValues()))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", jobDesc)
+	case "validate":
+		files := flagSet.Args()[1:]
+		if len(files) == 0 {
+			return fmt.Errorf("missing job descriptor files to validate")
+		}
+		// The descriptors would be validated with an empty commit, which passes the placeholder check
+		if webhookData.headSHA == "" {
+			return fmt.Errorf("missing commit to validate the job descriptors with, use --sha or --payload")
+		}
+		invalid, total := 0, 0
+		for _, file := range files {
+			// Every matrix combination of the config is validated, unless the values are given with --matrix
+			combinations := cd.Templates[filepath.Base(file)].MatrixCombinations()
+			if len(*flagMatrix) > 0 {
+				combinations = []client.MatrixCombination{{Values: flagMatrixValues()}}
+			}
+			for _, combination := range combinations {
+				total++
+				name := file + combination.Suffix
+				_, descriptor, err := renderJobFile(cd, file, webhookData.withMatrix(combination.Values))
+				if err == nil {
+					err = client.ValidateJobDescriptor(descriptor, cd.Validation)
+				}
+				if err != nil {
+					invalid++
+					fmt.Fprintf(stdout, "%s: %v\n", name, err)
+					continue
+				}
+				fmt.Fprintf(stdout, "%s: ok\n", name)
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d job descriptors are invalid", invalid, total)
+		}
+	}
+	return nil
+}
+
+// flagMatrixValues returns the matrix values of the --matrix flag
+func flagMatrixValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(*flagMatrix))
+	for axis, value := range *flagMatrix {
+		values[axis] = value
+	}
+	return values
+}
+
+// renderJobFile reads the job descriptor from the file or stdin, substitutes the templates with the
+// webhook data and the variables of the config file and the command line and converts it to JSON
+func renderJobFile(cd client.ClientDescriptor, path string, webhookData WebhookData) ([]byte, *client.JobDescriptor, error) {
+	jobDesc, err := readJobDescriptor(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	vars := cd.TemplateVars(filepath.Base(path))
+	for name, value := range *flagVars {
+		vars[name] = value
+	}
+	file := path
+	if file == "" {
+		file = "<stdin>"
+	}
+	return RenderJobDescriptor(file, jobDesc, webhookData, vars)
+}
+
+// offlineWebhookData returns the webhook data of the --payload file, the --sha flag overrides its commit
+func offlineWebhookData() (WebhookData, error) {
+	var webhookData WebhookData
+	if *flagPayload != "" {
+		payload, err := ioutil.ReadFile(*flagPayload)
+		if err != nil {
+			return webhookData, fmt.Errorf("could not read the webhook payload: %w", err)
+		}
+		eventType := *flagEvent
+		if eventType == "" {
+			eventType, err = detectEventType(payload)
+			if err != nil {
+				return webhookData, err
+			}
+		}
+		event, err := github.ParseWebHook(eventType, payload)
+		if err != nil {
+			return webhookData, fmt.Errorf("could not parse the webhook payload: %w", err)
+		}
+		switch e := event.(type) {
+		case *github.PullRequestEvent:
+			webhookData = eventData(clientapi.GithubPullRequestEvent(e))
+		case *github.PushEvent:
+			webhookData = eventData(clientapi.GithubPushEvent(e))
+		default:
+			return webhookData, fmt.Errorf("unsupported event type %s, use push or pull_request", eventType)
+		}
+	}
+	if *flagSHA != "" {
+		webhookData.headSHA = *flagSHA
+	}
+	return webhookData, nil
+}
+
+// detectEventType guesses the GitHub event type of the payload, pull request payloads contain the pull request
+func detectEventType(payload []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", fmt.Errorf("could not parse the webhook payload: %w", err)
+	}
+	if _, found := fields["pull_request"]; found {
+		return "pull_request", nil
+	}
+	return "push", nil
+}
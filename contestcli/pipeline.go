@@ -0,0 +1,143 @@
+package contestcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/transport"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// upstreamJob is the result of a job that a downstream template depends on
+type upstreamJob struct {
+	JobID   int
+	JobName string
+	Outputs map[string]string // Outputs of the template config, e.g. the URL of the uploaded binary
+}
+
+// outputData contains the template parameters of the outputs of a finished job
+type outputData struct {
+	templatedata
+	JobID   int
+	JobName string
+	Events  map[string][]string // Payloads of the test step events per event name, e.g. .Events.URL
+}
+
+// withUpstream returns a copy of the webhook data that passes the upstream jobs to the template
+func (webhookdata WebhookData) withUpstream(upstream map[string]upstreamJob) WebhookData {
+	webhookdata.upstream = upstream
+	return webhookdata
+}
+
+// withMatrix returns a copy of the webhook data that passes the matrix combination to the template
+func (webhookdata WebhookData) withMatrix(matrix map[string]interface{}) WebhookData {
+	webhookdata.matrix = matrix
+	return webhookdata
+}
+
+// upstreamResults collects the results of the upstream jobs of the template. If one of them did
+// not succeed, its name is returned as failed.
+func upstreamResults(cd client.ClientDescriptor, jobTemplate string, results map[string]*upstreamJob) (map[string]upstreamJob, string) {
+	upstream := make(map[string]upstreamJob)
+	for _, dependency := range cd.Templates[jobTemplate].DependsOn {
+		result := results[dependency]
+		if result == nil {
+			return nil, dependency
+		}
+		upstream[dependency] = *result
+	}
+	return upstream, ""
+}
+
+// skipJob reports the job of the template as not started, e.g. since an upstream job did not succeed
+func skipJob(ctx xcontext.Context, cd client.ClientDescriptor, jobTemplate string, combination client.MatrixCombination,
+	reason string, webhookData WebhookData) {
+	jobName := jobTemplate
+	if templateDescription, err := readJobTemplate(ctx, cd, jobTemplate, webhookData); err == nil {
+		if name, err := RetrieveJobName(jobTemplate, templateDescription); err == nil {
+			jobName = name
+		}
+	}
+	jobName += combination.Suffix
+	if err := clientapi.ActiveProviders().ReportStatus(ctx, webhookData.forge, clientapi.Status{Owner: webhookData.repoOwner,
+		Repo: webhookData.repoName, SHA: webhookData.headSHA, State: clientapi.StateError, Context: jobName + ". Test-Report:",
+		Description: "Not started, " + reason}); err != nil {
+		ctx.Warnf("could not change the commit status of the skipped job %s: %v", jobName, err)
+	}
+}
+
+// waitForUpstream waits for the job of the template to finish and renders its outputs.
+// It returns nil if the job did not succeed.
+func waitForUpstream(ctx context.Context, cd client.ClientDescriptor, transport transport.Transport, tracker client.JobTracker,
+	jobTemplate string, jobData client.RunData, webhookData WebhookData) (*upstreamJob, error) {
+	statusResp, err := client.WaitForJob(ctx, tracker, transport, *cd.Flags.FlagRequestor, jobData.JobID, time.Duration(*cd.Flags.FlagjobWaitPoll)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	status := statusResp.Data.Status
+	if !client.JobSucceeded(status) {
+		return nil, fmt.Errorf("the job finished in state %s", status.State)
+	}
+
+	// Collect the events of the test steps for the outputs
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the job status: %w", err)
+	}
+	events, err := client.JobEvents(statusJSON)
+	if err != nil {
+		return nil, err
+	}
+	data := outputData{
+		templatedata: webhookData.templateData(cd.TemplateVars(jobTemplate)),
+		JobID:        jobData.JobID,
+		JobName:      jobData.JobName,
+		Events:       events,
+	}
+	outputs, err := renderOutputs(cd.Templates[jobTemplate].Outputs, data)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamJob{JobID: jobData.JobID, JobName: jobData.JobName, Outputs: outputs}, nil
+}
+
+// renderOutputs substitutes the templates in the outputs of the template config
+func renderOutputs(outputs map[string]string, data outputData) (map[string]string, error) {
+	rendered := make(map[string]string, len(outputs))
+	for name, output := range outputs {
+		tmpl, err := template.New(name).Delims("[[", "]]").Funcs(templateFuncs()).Parse(output)
+		if err != nil {
+			return nil, fmt.Errorf("parse the output %s: %w", name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("template substitution of the output %s failed: %w", name, err)
+		}
+		rendered[name] = buf.String()
+	}
+	return rendered, nil
+}
+
+// mergeOutputs merges the outputs of all upstream jobs, in the order of the template names
+func mergeOutputs(upstream map[string]upstreamJob) map[string]string {
+	names := make([]string, 0, len(upstream))
+	for name := range upstream {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	outputs := make(map[string]string)
+	for _, name := range names {
+		for key, value := range upstream[name].Outputs {
+			outputs[key] = value
+		}
+	}
+	return outputs
+}
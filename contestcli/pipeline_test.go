@@ -0,0 +1,87 @@
+package contestcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/job"
+	"github.com/facebookincubator/contest/pkg/types"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// Test for run, if a resumed pipeline waits for the jobs that were started before the restart and
+// only starts the downstream stages if they succeeded
+func TestRunResumed(t *testing.T) {
+	dir := t.TempDir()
+	for name, jobName := range map[string]string{"build.json": "Build Test", "qemu.json": "QEMU Boot Test"} {
+		template := `{
+    "JobName": "` + jobName + `",
+    "Runs": 1,
+    "TestDescriptors": [{
+        "TargetManagerName": "TargetList",
+        "TargetManagerAcquireParameters": {},
+        "TestFetcherName": "literal",
+        "TestFetcherFetchParameters": {"TestName": "boot", "Steps": [{"name": "cmd", "label": "boot", "parameters": {}}]}
+    }],
+    "Reporting": {"RunReporters": [{"name": "TargetSuccess"}]}
+}`
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requestor := "9e-contestcli"
+	jobWaitPoll := 0
+	cd := client.ClientDescriptor{
+		Flags:           client.Flags{FlagRequestor: &requestor, FlagjobWaitPoll: &jobWaitPoll},
+		TemplateSources: []client.TemplateSource{{Dir: dir}},
+		Templates:       map[string]client.TemplateConfig{"qemu.json": {DependsOn: []string{"build.json"}}},
+	}
+	webhookData := WebhookData{forge: clientapi.ForgeGithub, repoOwner: "9elements", repoName: "coreboot",
+		headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", templates: []string{"qemu.json"}}
+	resumed := []client.RunData{{JobID: 7, JobName: "Build Test", Template: "build.json"}}
+
+	tests := []struct {
+		name       string
+		state      string
+		wantJobs   []string
+		wantStatus clientapi.Status
+	}{
+		{"upstream succeeded", string(job.EventJobCompleted), []string{"QEMU Boot Test"},
+			clientapi.Status{State: clientapi.StateRunning, Context: "QEMU Boot Test. Test-Report:"}},
+		{"upstream failed", string(job.EventJobFailed), nil,
+			clientapi.Status{State: clientapi.StateError, Context: "QEMU Boot Test. Test-Report:", Description: "Not started, build.json did not succeed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reporter := &fakeReporter{}
+			clientapi.SetProviders(&clientapi.Providers{Reporters: map[string]clientapi.StatusReporter{clientapi.ForgeGithub: reporter}})
+			defer clientapi.SetProviders(nil)
+			fake := &fakeTransport{states: map[types.JobID]string{7: test.state}}
+
+			jobs, err := run(xcontext.Background(), cd, fake, ioutil.Discard, webhookData, nil, nil, resumed, nil)
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			var got []string
+			for _, jobData := range jobs {
+				got = append(got, jobData.JobName)
+			}
+			if !reflect.DeepEqual(got, test.wantJobs) || len(fake.descriptors) != len(test.wantJobs) {
+				t.Errorf("got jobs %v and %d started want %v", got, len(fake.descriptors), test.wantJobs)
+			}
+			if len(reporter.statuses) != 1 {
+				t.Fatalf("got statuses %+v want a single one", reporter.statuses)
+			}
+			status := reporter.statuses[0]
+			if status.State != test.wantStatus.State || status.Context != test.wantStatus.Context || status.Description != test.wantStatus.Description {
+				t.Errorf("got %+v want %+v", status, test.wantStatus)
+			}
+		})
+	}
+}
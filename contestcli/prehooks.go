@@ -0,0 +1,82 @@
+package contestcli
+
+import (
+	"fmt"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// maxStatusDescription is the limit of the characters of the description of a GitHub commit status
+const maxStatusDescription = 140
+
+// vetoError is returned by startJob if a JobPreHook vetoed the job
+type vetoError struct {
+	reason string
+}
+
+func (e *vetoError) Error() string {
+	return "the job was vetoed: " + e.reason
+}
+
+// runJobPreHooks passes the rendered job to the PreJobExecutionHooks that implement client.JobPreHook.
+// If a hook changed the template variables, the template is rendered again, then the tags and the steps
+// of the hooks are added. A vetoed job is reported as commit status and returned as vetoError.
+func runJobPreHooks(ctx xcontext.Context, cd client.ClientDescriptor, preHooks []*client.PreHookExecutionBundle, jobTemplate string,
+	jobName string, templateDescription []byte, webhookData WebhookData, jobDesc []byte, descriptor *client.JobDescriptor,
+	vars map[string]interface{}) ([]byte, *client.JobDescriptor, error) {
+	job := client.NewPreJobContext(webhookData.clientEvent(), jobTemplate, jobName, descriptor, vars)
+	hooked := false
+	for _, bundle := range preHooks {
+		hook, ok := bundle.PreJobExecutionHooks.(client.JobPreHook)
+		if !ok {
+			continue
+		}
+		hooked = true
+		if err := hook.PreJob(ctx, bundle.Parameters, cd, job); err != nil {
+			return nil, nil, fmt.Errorf("PreJobExecutionHook failed for the job %s: %w", jobName, err)
+		}
+		if reason, vetoed := job.Vetoed(); vetoed {
+			reportVeto(ctx, jobName, reason, webhookData)
+			return nil, nil, &vetoError{reason: reason}
+		}
+	}
+	if !hooked {
+		return jobDesc, descriptor, nil
+	}
+
+	// Render the template again with the variables of the hooks
+	var err error
+	if vars, changed := job.Vars(); changed {
+		jobDesc, descriptor, err = RenderJobDescriptor(jobTemplate, templateDescription, webhookData, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Add the tags and the steps of the hooks
+	if job.Changed() {
+		if descriptor, err = job.Apply(descriptor); err != nil {
+			return nil, nil, err
+		}
+		if jobDesc, err = descriptor.JSON(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return jobDesc, descriptor, nil
+}
+
+// reportVeto sets the commit status of the vetoed job to error with the reason of the hook,
+// the description is truncated to the characters GitHub accepts
+func reportVeto(ctx xcontext.Context, jobName string, reason string, webhookData WebhookData) {
+	description := []rune("Vetoed: " + reason)
+	if len(description) > maxStatusDescription {
+		description = append(description[:maxStatusDescription-3], []rune("...")...)
+	}
+	if err := clientapi.ActiveProviders().ReportStatus(ctx, webhookData.forge, clientapi.Status{Owner: webhookData.repoOwner,
+		Repo: webhookData.repoName, SHA: webhookData.headSHA, State: clientapi.StateError, Context: jobName + ". Test-Report:",
+		Description: string(description)}); err != nil {
+		ctx.Warnf("could not change the commit status of the vetoed job %s: %v", jobName, err)
+	}
+}
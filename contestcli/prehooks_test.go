@@ -0,0 +1,112 @@
+package contestcli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/transport"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// fakeJobPreHook changes every job it sees
+type fakeJobPreHook struct {
+	change func(job *client.PreJobContext)
+}
+
+func (h *fakeJobPreHook) Run(ctx context.Context, parameters interface{}, cd client.ClientDescriptor, transport transport.Transport) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *fakeJobPreHook) ValidateParameters(params []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (h *fakeJobPreHook) PreJob(ctx context.Context, parameters interface{}, cd client.ClientDescriptor, job *client.PreJobContext) error {
+	h.change(job)
+	return nil
+}
+
+// Test for runJobPreHooks, if the changes of the hooks end up in the job descriptor
+func TestRunJobPreHooks(t *testing.T) {
+	template := []byte(`{"JobName": "Build [[ .Vars.Board | default ` + "`qemu`" + ` ]]", "Tags": []}`)
+	webhookData := WebhookData{repoOwner: "9elements", repoName: "coreboot", headSHA: "0123456"}
+
+	tests := []struct {
+		name   string
+		change func(job *client.PreJobContext)
+		want   string
+		tags   []string
+		vetoed bool
+	}{
+		{"unchanged", func(job *client.PreJobContext) {}, "Build qemu", nil, false},
+		{"variable", func(job *client.PreJobContext) { job.SetVar("Board", "archercity") }, "Build archercity", nil, false},
+		{"tags", func(job *client.PreJobContext) { job.AddTags(job.Event.SHA) }, "Build qemu", []string{"0123456"}, false},
+		{"veto", func(job *client.PreJobContext) { job.Veto("maintenance") }, "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jobDesc, descriptor, err := RenderJobDescriptor("build.json", template, webhookData, nil)
+			if err != nil {
+				t.Fatalf("RenderJobDescriptor failed: %v", err)
+			}
+			hooks := []*client.PreHookExecutionBundle{{PreJobExecutionHooks: &fakeJobPreHook{change: test.change}}}
+			_, descriptor, err = runJobPreHooks(xcontext.Background(), client.ClientDescriptor{}, hooks, "build.json", descriptor.JobName,
+				template, webhookData, jobDesc, descriptor, nil)
+			var veto *vetoError
+			if errors.As(err, &veto) != test.vetoed {
+				t.Fatalf("got %v want vetoed %v", err, test.vetoed)
+			}
+			if test.vetoed {
+				return
+			}
+			if err != nil {
+				t.Fatalf("runJobPreHooks failed: %v", err)
+			}
+			if descriptor.JobName != test.want {
+				t.Errorf("got %q want %q", descriptor.JobName, test.want)
+			}
+			if len(descriptor.Tags) != len(test.tags) || (len(test.tags) > 0 && descriptor.Tags[0] != test.tags[0]) {
+				t.Errorf("got %v want %v", descriptor.Tags, test.tags)
+			}
+		})
+	}
+}
+
+// fakeReporter records the reported statuses instead of setting them on a forge
+type fakeReporter struct {
+	statuses []clientapi.Status
+}
+
+func (f *fakeReporter) ReportStatus(ctx context.Context, status clientapi.Status) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+// Test for reportVeto, if the vetoed job is reported as error on the forge of the webhook
+func TestReportVeto(t *testing.T) {
+	github, gitlab := &fakeReporter{}, &fakeReporter{}
+	clientapi.SetProviders(&clientapi.Providers{Reporters: map[string]clientapi.StatusReporter{
+		clientapi.ForgeGithub: github,
+		clientapi.ForgeGitlab: gitlab,
+	}})
+	defer clientapi.SetProviders(nil)
+
+	webhookData := WebhookData{forge: clientapi.ForgeGitlab, repoOwner: "firmware", repoName: "coreboot", headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}
+	// The description is truncated by characters, not in the middle of one
+	reportVeto(xcontext.Background(), "Build Test", strings.Repeat("ü", 200), webhookData)
+
+	if len(github.statuses) != 0 || len(gitlab.statuses) != 1 {
+		t.Fatalf("got %v on github and %v on gitlab want one status on gitlab", github.statuses, gitlab.statuses)
+	}
+	got := gitlab.statuses[0]
+	if got.State != clientapi.StateError || got.Context != "Build Test. Test-Report:" || got.SHA != webhookData.headSHA ||
+		utf8.RuneCountInString(got.Description) != maxStatusDescription || !utf8.ValidString(got.Description) ||
+		!strings.HasPrefix(got.Description, "Vetoed: ") || !strings.HasSuffix(got.Description, "ü...") {
+		t.Errorf("got %+v want the truncated veto as error", got)
+	}
+}
@@ -0,0 +1,165 @@
+package contestcli
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/transport"
+	"github.com/facebookincubator/contest/pkg/types"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// branchJobs remembers the jobs that are running for every branch or pull request,
+// so that they can be cancelled as soon as a newer commit is pushed
+type branchJobs struct {
+	lock  sync.Mutex
+	jobs  map[string][]client.RunData
+	heads map[string]string // Latest commit of every branch, jobs of other commits are superseded
+}
+
+func newBranchJobs() *branchJobs {
+	return &branchJobs{jobs: make(map[string][]client.RunData), heads: make(map[string]string)}
+}
+
+// supersede makes sha the latest commit of the branch, it forgets and returns all jobs of the branch
+// that were started for another commit
+func (b *branchJobs) supersede(branch string, sha string) []client.RunData {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.heads[branch] = sha
+	var superseded, current []client.RunData
+	for _, jobData := range b.jobs[branch] {
+		if jobData.JobSHA == sha {
+			current = append(current, jobData)
+		} else {
+			superseded = append(superseded, jobData)
+		}
+	}
+	b.set(branch, current)
+	return superseded
+}
+
+// cancel forgets and returns all jobs of the branch
+func (b *branchJobs) cancel(branch string) []client.RunData {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	cancelled := b.jobs[branch]
+	delete(b.jobs, branch)
+	return cancelled
+}
+
+// add remembers the jobs that were started for the branch. Jobs of a commit that was superseded by another
+// delivery while they were started are not remembered, they are returned to be cancelled.
+func (b *branchJobs) add(branch string, rundata []client.RunData) []client.RunData {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var superseded []client.RunData
+	for _, jobData := range rundata {
+		if head, found := b.heads[branch]; found && jobData.JobSHA != head {
+			superseded = append(superseded, jobData)
+			continue
+		}
+		b.jobs[branch] = append(b.jobs[branch], jobData)
+	}
+	return superseded
+}
+
+// head returns the latest commit of the branch
+func (b *branchJobs) head(branch string) string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.heads[branch]
+}
+
+// remove forgets the jobs of the branch, e.g. after they finished
+func (b *branchJobs) remove(branch string, rundata []client.RunData) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var remaining []client.RunData
+	for _, jobData := range b.jobs[branch] {
+		if !containsJob(rundata, jobData.JobID) {
+			remaining = append(remaining, jobData)
+		}
+	}
+	b.set(branch, remaining)
+}
+
+// set replaces the jobs of the branch, the caller has to hold the lock
+func (b *branchJobs) set(branch string, rundata []client.RunData) {
+	if len(rundata) == 0 {
+		delete(b.jobs, branch)
+		return
+	}
+	b.jobs[branch] = rundata
+}
+
+// containsJob returns true if a job with the jobID is part of the rundata
+func containsJob(rundata []client.RunData, jobID int) bool {
+	for _, jobData := range rundata {
+		if jobData.JobID == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelSuperseded stops the superseded jobs on the server and marks their commit statuses as superseded
+func cancelSuperseded(ctx xcontext.Context, transport transport.Transport, requestor string,
+	superseded []client.RunData, sha string) {
+	cancelJobs(ctx, transport, requestor, superseded, "Superseded by commit "+shortSHA(sha))
+}
+
+// cancelJobs stops the jobs that are still running on the server and sets their commit statuses
+// to error with the description
+func cancelJobs(ctx xcontext.Context, transport transport.Transport, requestor string,
+	jobs []client.RunData, description string) {
+	for _, jobData := range jobs {
+		// Jobs that already finished keep their result
+		statusResp, err := client.JobStatus(ctx, transport, requestor, jobData.JobID)
+		if err != nil {
+			ctx.Warnf("could not retrieve the status of the job %d: %v", jobData.JobID, err)
+			continue
+		}
+		if client.JobCompleted(statusResp.Data.Status) {
+			continue
+		}
+		ctx.Infof("cancelling job %d (%s) for commit %s: %s", jobData.JobID, jobData.JobName, jobData.JobSHA, description)
+		if err := stopJob(ctx, transport, requestor, jobData.JobID); err != nil {
+			ctx.Warnf("could not cancel the job %d: %v", jobData.JobID, err)
+			continue
+		}
+		err = clientapi.ActiveProviders().ReportStatus(ctx, jobData.Forge, clientapi.Status{Owner: jobData.RepoOwner, Repo: jobData.RepoName,
+			SHA: jobData.JobSHA, State: clientapi.StateError, Context: jobData.JobName + ". Test-Report:",
+			Description: description})
+		if err != nil {
+			ctx.Warnf("could not set the commit status of the cancelled job %d: %v", jobData.JobID, err)
+		}
+	}
+}
+
+// stopJob requests the server to stop the job
+func stopJob(ctx context.Context, transport transport.Transport, requestor string, jobID int) error {
+	stopResp, err := transport.Stop(ctx, requestor, types.JobID(jobID))
+	if err != nil {
+		return fmt.Errorf("could not send the stop request to the server: %w", err)
+	}
+	if stopResp.Err != nil {
+		return fmt.Errorf("the server could not stop the job: %v", stopResp.Err)
+	}
+	return nil
+}
+
+// shortSHA returns the abbreviated form of a commit sha
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,64 @@
+package contestcli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// Test for branchJobs, if the jobs of older commits on a branch are superseded
+func TestBranchJobs(t *testing.T) {
+	oldJob := client.RunData{JobID: 1, JobName: "Build Test", JobSHA: "old"}
+	otherBranchJob := client.RunData{JobID: 2, JobName: "Build Test", JobSHA: "other"}
+	newJob := client.RunData{JobID: 3, JobName: "Build Test", JobSHA: "new"}
+
+	jobs := newBranchJobs()
+	jobs.add("repo#main", []client.RunData{oldJob})
+	jobs.add("repo#feature", []client.RunData{otherBranchJob})
+
+	// Define want and got and compare them
+	got := jobs.supersede("repo#main", "new")
+	want := []client.RunData{oldJob}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	// The jobs of the new commit must not be superseded by a redelivery of the same webhook
+	jobs.add("repo#main", []client.RunData{newJob})
+	if got := jobs.supersede("repo#main", "new"); len(got) != 0 {
+		t.Errorf("got %v want no superseded jobs", got)
+	}
+
+	// Removed jobs are forgotten
+	jobs.remove("repo#main", []client.RunData{newJob})
+	if got := jobs.supersede("repo#main", "newer"); len(got) != 0 {
+		t.Errorf("got %v want no superseded jobs", got)
+	}
+	if got := jobs.supersede("repo#feature", "newer"); !reflect.DeepEqual(got, []client.RunData{otherBranchJob}) {
+		t.Errorf("got %v want %v", got, []client.RunData{otherBranchJob})
+	}
+}
+
+// Test for branchJobs, if the jobs of a commit that was superseded while they were started are not kept
+func TestBranchJobsConcurrentDeliveries(t *testing.T) {
+	oldJob := client.RunData{JobID: 1, JobName: "Build Test", JobSHA: "old"}
+	newJob := client.RunData{JobID: 2, JobName: "Build Test", JobSHA: "new"}
+
+	// Both deliveries supersede before either of them started its jobs
+	jobs := newBranchJobs()
+	jobs.supersede("repo#main", "old")
+	jobs.supersede("repo#main", "new")
+	if got := jobs.add("repo#main", []client.RunData{newJob}); len(got) != 0 {
+		t.Errorf("got %v want no superseded jobs", got)
+	}
+	if got, want := jobs.add("repo#main", []client.RunData{oldJob}), []client.RunData{oldJob}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := jobs.cancel("repo#main"), []client.RunData{newJob}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := jobs.head("repo#main"), "new"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package contestcli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// templateFuncs returns the functions that can be used in the [[ ]] expressions of the job templates.
+// The names and the argument order follow Sprig, so the piped value is always the last argument,
+// e.g. [[ .SHA | shortSHA ]] or [[ .Vars.HostPrefix | default `yv3-evt-slot` ]]. The results of
+// toJson, toYaml, quote, squote and raw are printed without escaping, see escapeTemplate.
+func templateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		// Strings
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      strings.Title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep string, s string) []string { return strings.Split(s, sep) },
+		"join":       func(sep string, list []string) string { return strings.Join(list, sep) },
+		"first":      first,
+		"trunc":      trunc,
+		"shortSHA":   shortSHA,
+		"quote":      func(v interface{}) string { return jsonString(fmt.Sprint(v), true) },
+		"squote":     func(v interface{}) string { return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'" },
+		"raw":        printValue,
+
+		// Defaults and environment
+		"default": defaultValue,
+		"empty":   empty,
+		"env":     templateEnv,
+
+		// Dates
+		"now":  time.Now,
+		"date": func(layout string, t time.Time) string { return t.Format(layout) },
+
+		// Encodings
+		"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec": b64dec,
+		"toJson": toJSON,
+		"toYaml": toYAML,
+	}
+}
+
+// templateEnvPrefix is the prefix of the env variables that the job templates can read. The tokens and
+// secrets of the forges must not end up in the job descriptors that are sent to the server.
+const templateEnvPrefix = "CONTEST_"
+
+// templateEnv returns the value of the env variable, variables without templateEnvPrefix are rejected
+func templateEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, templateEnvPrefix) {
+		return "", fmt.Errorf("the env variable %s can not be read by job templates, only variables with the prefix %s", name, templateEnvPrefix)
+	}
+	return os.Getenv(name), nil
+}
+
+// trunc cuts the string after length characters
+func trunc(length int, s string) string {
+	if runes := []rune(s); length >= 0 && len(runes) > length {
+		return string(runes[:length])
+	}
+	return s
+}
+
+// first returns the first element of the list or an empty string
+func first(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
+
+// defaultValue returns the value, or def if the value is empty
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+// empty returns true if the value is nil or the zero value of its type, or an empty slice or map
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// b64dec decodes the base64 encoded string
+func b64dec(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("could not decode base64 string: %w", err)
+	}
+	return string(data), nil
+}
+
+// toJSON encodes the value as JSON, strings become quoted and escaped JSON strings
+// that are valid in JSON and YAML descriptors
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode the value as JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// toYAML encodes the value as YAML without the trailing newline
+func toYAML(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode the value as YAML: %w", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,58 @@
+package contestcli
+
+import (
+	"os"
+	"testing"
+)
+
+// Test for the template functions and variables, if they are substituted in the job descriptor
+func TestTemplateFuncs(t *testing.T) {
+	webhookData := WebhookData{headSHA: "0123456789abcdef0123456789abcdef01234567", refSHA: "feature/Foo"}
+	vars := map[string]interface{}{"HostPrefix": "tp-slot", "CPUs": 4, "Board": "Überboard", "Message": "fix\x01 ä"}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		// A short SHA of digits would be read as a number, it is quoted
+		{"short sha", "[[ .SHA | shortSHA ]]", `"0123456"`},
+		{"variable", "[[ .Vars.HostPrefix ]]-[[ .Vars.CPUs ]]", "tp-slot-4"},
+		{"default of missing variable", "[[ .Vars.Missing | default `yv3-evt-slot` ]]", "yv3-evt-slot"},
+		{"default of set variable", "[[ .Vars.HostPrefix | default `yv3-evt-slot` ]]", "tp-slot"},
+		{"string functions", "[[ .Branch | replace `/` `-` | lower ]]", "feature-foo"},
+		{"trim prefix", "[[ .Branch | trimPrefix `feature/` | upper ]]", "FOO"},
+		{"trunc", "[[ .Vars.Board | trunc 3 ]]", "Übe"},
+		{"quote", "[[ .Vars.Message | quote ]]", `"fix\u0001 ä"`},
+		{"base64", "[[ `contest` | b64enc ]]", "Y29udGVzdA=="},
+		{"base64 roundtrip", "[[ `contest` | b64enc | b64dec ]]", "contest"},
+		{"date", "[[ now | date `2006` | len ]]", "4"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ChangeJobDescriptor([]byte(test.template), webhookData, vars, true)
+			if err != nil {
+				t.Fatalf("ChangeJobDescriptor failed: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// Test for the env function, if only the variables with the CONTEST_ prefix can be read
+func TestTemplateEnv(t *testing.T) {
+	os.Setenv("CONTEST_LAB", "bochum")
+	defer os.Unsetenv("CONTEST_LAB")
+	os.Setenv("GITHUB_TOKEN", "token")
+	defer os.Unsetenv("GITHUB_TOKEN")
+
+	got, err := ChangeJobDescriptor([]byte("[[ env `CONTEST_LAB` ]]"), WebhookData{}, nil, true)
+	if err != nil || string(got) != "bochum" {
+		t.Errorf("got %q, %v want %q", got, err, "bochum")
+	}
+	if got, err := ChangeJobDescriptor([]byte("[[ env `GITHUB_TOKEN` ]]"), WebhookData{}, nil, true); err == nil {
+		t.Errorf("got %q want an error", got)
+	}
+}
@@ -0,0 +1,121 @@
+package contestcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+)
+
+// templateHTTPTimeout limits the download of a job template from an URL source
+const templateHTTPTimeout = 30 * time.Second
+
+// maxTemplateSize limits the size of a job template that is downloaded from an URL source
+const maxTemplateSize = 1 << 20
+
+// errTemplateNotFound is returned by a template source that does not have the job template
+var errTemplateNotFound = errors.New("job template not found")
+
+// defaultTemplateSources is used if the config file does not configure any template source
+var defaultTemplateSources = []client.TemplateSource{{Dir: "descriptors"}}
+
+// readJobTemplate reads the jobTemplate from the first template source of the config that has it
+func readJobTemplate(ctx context.Context, cd client.ClientDescriptor, jobTemplate string, webhookData WebhookData) ([]byte, error) {
+	sources := cd.TemplateSources
+	if len(sources) == 0 {
+		sources = defaultTemplateSources
+	}
+	for _, source := range sources {
+		templateDescription, err := readTemplateSource(ctx, source, jobTemplate, webhookData)
+		if errors.Is(err, errTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read the jobtemplate %s: %w", jobTemplate, err)
+		}
+		return templateDescription, nil
+	}
+	return nil, fmt.Errorf("could not read the jobtemplate %s: %w", jobTemplate, errTemplateNotFound)
+}
+
+// readTemplateSource reads the jobTemplate from a single template source. If the source
+// does not have the template, errTemplateNotFound is returned.
+func readTemplateSource(ctx context.Context, source client.TemplateSource, jobTemplate string, webhookData WebhookData) ([]byte, error) {
+	// The template name must not escape the directory of the source
+	if jobTemplate == "" || path.Clean("/"+jobTemplate) != "/"+jobTemplate {
+		return nil, fmt.Errorf("invalid jobtemplate name %q", jobTemplate)
+	}
+
+	switch {
+	case source.Dir != "":
+		templateDescription, err := os.ReadFile(filepath.Join(source.Dir, filepath.FromSlash(jobTemplate)))
+		if os.IsNotExist(err) {
+			return nil, errTemplateNotFound
+		}
+		return templateDescription, err
+	case source.URL != "":
+		return readTemplateURL(ctx, strings.TrimSuffix(source.URL, "/")+"/"+jobTemplate)
+	case source.RepoPath != "":
+		if webhookData.repoName == "" || webhookData.headSHA == "" {
+			return nil, errTemplateNotFound
+		}
+		// Pull requests of forks and Gerrit patchsets could run any command on the targets with their own templates
+		if !webhookData.trustedHead() && !source.AllowForks {
+			return nil, errTemplateNotFound
+		}
+		reporter, err := clientapi.ActiveProviders().Reporter(webhookData.forge)
+		if err != nil {
+			return nil, errTemplateNotFound
+		}
+		reader, ok := reporter.(clientapi.FileReader)
+		if !ok {
+			return nil, errTemplateNotFound
+		}
+		templateDescription, err := reader.GetFileContent(ctx, webhookData.repoOwner, webhookData.repoName,
+			path.Join(source.RepoPath, jobTemplate), webhookData.headSHA)
+		if errors.Is(err, clientapi.ErrNotFound) {
+			return nil, errTemplateNotFound
+		}
+		return templateDescription, err
+	}
+	return nil, fmt.Errorf("the template source has neither Dir, URL nor RepoPath")
+}
+
+// readTemplateURL downloads the job template, a missing template is reported as errTemplateNotFound
+func readTemplateURL(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, templateHTTPTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errTemplateNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	templateDescription, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTemplateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(templateDescription) > maxTemplateSize {
+		return nil, fmt.Errorf("GET %s: the job template is larger than %d bytes", url, maxTemplateSize)
+	}
+	return templateDescription, nil
+}
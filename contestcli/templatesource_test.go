@@ -0,0 +1,121 @@
+package contestcli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+)
+
+// Test for the template sources, if the first source that has the job template is used
+func TestReadJobTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.yaml"), []byte("JobName: local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/templates/local.yaml", "/templates/remote.yaml":
+			w.Write([]byte("JobName: remote"))
+		case "/templates/broken.yaml":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/templates/huge.yaml":
+			w.Write(make([]byte, maxTemplateSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cd := client.ClientDescriptor{TemplateSources: []client.TemplateSource{
+		// Pull requests of forks skip the repository source, without a GitHub client
+		{RepoPath: ".contest", AllowForks: false},
+		{Dir: dir},
+		{URL: server.URL + "/templates/"},
+	}}
+	fork := WebhookData{repoOwner: "someone", repoName: "coreboot", headSHA: "0123456", sshURL: "git@github.com:someone/coreboot.git",
+		baseSSHURL: "git@github.com:9elements/coreboot.git"}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		notFound bool
+	}{
+		{"local directory first", "local.yaml", "JobName: local", false},
+		{"url", "remote.yaml", "JobName: remote", false},
+		{"missing", "missing.yaml", "", true},
+		{"server error", "broken.yaml", "", false},
+		{"too large", "huge.yaml", "", false},
+		{"outside of the sources", "../local.yaml", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readJobTemplate(context.Background(), cd, test.template, fork)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("got %q want an error", got)
+				}
+				if errors.Is(err, errTemplateNotFound) != test.notFound {
+					t.Errorf("got %v want not found %v", err, test.notFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readJobTemplate failed: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// fakeFileReader serves the same template for every file of every repository
+type fakeFileReader struct {
+	fakeReporter
+}
+
+func (f *fakeFileReader) GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error) {
+	return []byte("JobName: repository"), nil
+}
+
+// Test for the RepoPath source, if only the templates of trusted commits are loaded unless forks are allowed
+func TestRepoPathTrust(t *testing.T) {
+	clientapi.SetProviders(&clientapi.Providers{Reporters: map[string]clientapi.StatusReporter{
+		clientapi.ForgeGithub: &fakeFileReader{},
+		clientapi.ForgeGerrit: &fakeFileReader{},
+	}})
+	defer clientapi.SetProviders(nil)
+
+	push := WebhookData{forge: clientapi.ForgeGithub, repoOwner: "9elements", repoName: "coreboot", headSHA: "0123456",
+		sshURL: "git@github.com:9elements/coreboot.git", baseSSHURL: "git@github.com:9elements/coreboot.git"}
+	patchSet := WebhookData{forge: clientapi.ForgeGerrit, repoName: "coreboot", headSHA: "0123456",
+		sshURL: "ssh://review.coreboot.org:29418/coreboot", baseSSHURL: "ssh://review.coreboot.org:29418/coreboot"}
+
+	tests := []struct {
+		name        string
+		webhookData WebhookData
+		allowForks  bool
+		found       bool
+	}{
+		{"push", push, false, true},
+		{"gerrit patchset", patchSet, false, false},
+		{"gerrit patchset allowed", patchSet, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := client.TemplateSource{RepoPath: ".contest", AllowForks: test.allowForks}
+			_, err := readTemplateSource(context.Background(), source, "build.yaml", test.webhookData)
+			if got := err == nil; got != test.found {
+				t.Errorf("got %v (err %v) want found %v", got, err, test.found)
+			}
+		})
+	}
+}
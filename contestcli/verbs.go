@@ -1,172 +1,489 @@
 package contestcli
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
-	"net/http"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/9elements/contest-client/pkg/client"
 	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/event"
+	"github.com/facebookincubator/contest/pkg/job"
 	"github.com/facebookincubator/contest/pkg/transport"
-	"github.com/icza/dyno"
-	"gopkg.in/yaml.v2"
+	"github.com/facebookincubator/contest/pkg/types"
+	"github.com/facebookincubator/contest/pkg/xcontext"
 )
 
 // Struct that contains all possible template parameters
 type templatedata struct {
-	SHA string
+	SHA          string   // Commit that triggered the job, the head of a pull request
+	BaseSHA      string   // Head of the base branch of a pull request, the previous commit of a push
+	Event        string   // GitHub event type, e.g. "push" or "pull_request"
+	Ref          string   // Full git ref, e.g. "refs/heads/main" or "refs/pull/42/head"
+	Branch       string   // Branch that was pushed or the head branch of a pull request
+	BaseBranch   string   // Base branch of a pull request, the pushed branch for pushes
+	RepoOwner    string   // Owner of the repository that received the webhook
+	RepoName     string   // Name of the repository that received the webhook
+	RepoFullName string   // "owner/name" of the repository that received the webhook
+	CloneURL     string   // HTTPS clone URL of the repository that contains SHA, e.g. a fork
+	SSHURL       string   // SSH clone URL of the repository that contains SHA, e.g. a fork
+	BaseCloneURL string   // HTTPS clone URL of the repository that received the webhook
+	BaseSSHURL   string   // SSH clone URL of the repository that received the webhook
+	PRNumber     int      // Number of the pull request or Gerrit change, 0 for pushes
+	PatchSet     int      // Number of the patchset of the Gerrit change, 0 for other forges
+	Title        string   // Title of the pull request or subject of the pushed head commit
+	Author       string   // Login of the pull request author or the pusher
+	ChangedFiles []string // Files changed by the pull request or the pushed commits
+
+	Vars     map[string]interface{} // Variables of the clientconfig.json and the job template
+	Upstream map[string]upstreamJob // Finished upstream jobs per template name, see DependsOn
+	Outputs  map[string]string      // Outputs of all upstream jobs, e.g. .Outputs.BinaryURL
+	Matrix   map[string]interface{} // Values of the matrix combination of the job, e.g. .Matrix.HostPrefix
+}
+
+// templateData returns the template parameters of the webhook and the variables
+func (webhookdata WebhookData) templateData(vars map[string]interface{}) templatedata {
+	fullName := ""
+	if webhookdata.repoOwner != "" || webhookdata.repoName != "" {
+		fullName = webhookdata.fullName()
+	}
+	return templatedata{
+		SHA:          webhookdata.headSHA,
+		BaseSHA:      webhookdata.baseSHA,
+		Event:        webhookdata.event,
+		Ref:          webhookdata.ref,
+		Branch:       webhookdata.refSHA,
+		BaseBranch:   webhookdata.baseRef,
+		RepoOwner:    webhookdata.repoOwner,
+		RepoName:     webhookdata.repoName,
+		RepoFullName: fullName,
+		CloneURL:     webhookdata.cloneURL,
+		SSHURL:       webhookdata.sshURL,
+		BaseCloneURL: webhookdata.baseCloneURL,
+		BaseSSHURL:   webhookdata.baseSSHURL,
+		PRNumber:     webhookdata.prNumber,
+		PatchSet:     webhookdata.patchSet,
+		Title:        webhookdata.title,
+		Author:       webhookdata.author,
+		ChangedFiles: webhookdata.changedFiles,
+		Vars:         vars,
+		Upstream:     webhookdata.upstream,
+		Outputs:      mergeOutputs(webhookdata.upstream),
+		Matrix:       webhookdata.matrix,
+	}
 }
 
 /* Function run runs the main functionility of the contest-client.
    It creates new jobDescriptors and kicks off new jobs.
    It also sets the github commit status to pending if the job was started */
-func run(ctx context.Context, cd client.ClientDescriptor, transport transport.Transport, stdout io.Writer,
-	webhookData WebhookData) ([]client.RunData, error) {
+func run(ctx xcontext.Context, cd client.ClientDescriptor, transport transport.Transport, stdout io.Writer,
+	webhookData WebhookData, preHooks []*client.PreHookExecutionBundle, postHooks []*client.PostHookExecutionBundle,
+	resumed []client.RunData, started func([]client.RunData) error) ([]client.RunData, error) {
 
 	// Declare a jobs []struct that contains the rundata that shall be passed
 	var jobs []client.RunData
 
-	// Iterate over all JobTemplates that are defined in the clientconfig.json
-	for i := 0; i < len(cd.Flags.FlagJobTemplate); i++ {
+	// Create the tracker that keeps track of the job status
+	tracker := client.NewJobTracker(cd, transport)
 
-		// Create Path to the jobTemplate
-		filePath, _ := filepath.Abs("descriptors/")
-		filePathTemplate := strings.Join([]string{filePath, *cd.Flags.FlagJobTemplate[i]}, "/")
+	// Select the JobTemplates of the routes in the clientconfig.json that match the webhook,
+	// unless a command of the pull request selected them
+	templates := webhookData.templates
+	if templates == nil {
+		templates = cd.JobTemplates(webhookData.clientEvent())
+	}
+	if len(templates) == 0 {
+		fmt.Fprintf(stdout, "no job templates match the webhook for %s/%s, branch %s\n", webhookData.repoOwner, webhookData.repoName, webhookData.baseRef)
+	}
 
-		// Parse the json/yaml file
-		templateDescription, err := os.ReadFile(filePathTemplate)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse the jobtemplate: %w", err)
-		}
+	// Order the JobTemplates into stages by their dependencies
+	stages, err := cd.PipelineStages(templates)
+	if err != nil {
+		return nil, err
+	}
+	var all []string
+	for _, stage := range stages {
+		all = append(all, stage...)
+	}
 
-		// Retrieve the jobName for further usages
-		jobName, err := RetrieveJobName(templateDescription, *cd.Flags.FlagYAML)
-		if err != nil {
-			return nil, fmt.Errorf("could not retrieve the job name: %w", err)
-		}
+	// The jobs that were started before a restart are not started again, the pipeline waits for them instead
+	resumedJobs := make(map[string]client.RunData, len(resumed))
+	for _, jobData := range resumed {
+		resumedJobs[jobData.Template+jobData.Combination] = jobData
+	}
 
-		// Adapt the jobDescriptor based on the webhookdata
-		jobDesc, err := ChangeJobDescriptor(templateDescription, webhookData)
-		if err != nil {
-			return nil, fmt.Errorf("could not change the job template: %w", err)
+	// Results of the jobs that downstream templates depend on, nil if the job did not succeed
+	results := make(map[string]*upstreamJob)
+	for _, stage := range stages {
+		var stageJobs, startedJobs []client.RunData
+		var stageTemplates []string
+		var stageCombinations []client.MatrixCombination
+		failedTemplates := make(map[string]bool)
+		for _, jobTemplate := range stage {
+			// A job is started for every combination of the matrix of the template
+			combinations := cd.Templates[jobTemplate].MatrixCombinations()
+
+			// Only start the jobs if all upstream jobs succeeded
+			upstream, failed := upstreamResults(cd, jobTemplate, results)
+			for _, combination := range combinations {
+				if jobData, found := resumedJobs[jobTemplate+combination.Suffix]; found {
+					stageJobs = append(stageJobs, jobData)
+					stageTemplates = append(stageTemplates, jobTemplate)
+					stageCombinations = append(stageCombinations, combination)
+					continue
+				}
+				if failed != "" {
+					skipJob(ctx, cd, jobTemplate, combination, failed+" did not succeed", webhookData)
+					continue
+				}
+				jobData, err := startJob(ctx, cd, transport, tracker, preHooks, postHooks, jobTemplate, combination, webhookData.withUpstream(upstream))
+				// A vetoed job fails its template, the downstream templates are not started
+				var veto *vetoError
+				if errors.As(err, &veto) {
+					fmt.Fprintf(stdout, "the job of %s%s was not started: %v\n", jobTemplate, combination.Suffix, err)
+					failedTemplates[jobTemplate] = true
+					continue
+				}
+				// The jobs of the stage that were already started keep running, they are returned with the error
+				if err != nil {
+					return append(jobs, startedJobs...), err
+				}
+				stageJobs = append(stageJobs, jobData)
+				startedJobs = append(startedJobs, jobData)
+				stageTemplates = append(stageTemplates, jobTemplate)
+				stageCombinations = append(stageCombinations, combination)
+			}
+		}
+		jobs = append(jobs, startedJobs...)
+		if started != nil && len(startedJobs) > 0 {
+			if err := started(startedJobs); err != nil {
+				return jobs, err
+			}
 		}
 
-		// If template file is YAML convert it to JSON
-		if *cd.Flags.FlagYAML {
-			// Unmarshal the data in a map
-			var body interface{}
-			err := yaml.Unmarshal(jobDesc, &body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse YAML job descriptor: %w", err)
+		// Wait for the jobs that downstream templates depend on. The template only succeeded if
+		// the jobs of all matrix combinations succeeded. Templates with a matrix have no outputs,
+		// see client.PipelineStages, so the result of the first combination is passed on.
+		for i, jobData := range stageJobs {
+			jobTemplate := stageTemplates[i]
+			if !cd.Upstream(jobTemplate, all) || failedTemplates[jobTemplate] {
+				continue
 			}
-			body = dyno.ConvertMapI2MapS(body)
-			// then marshal the structure back to JSON
-			jobDesc, err = json.MarshalIndent(body, "", "    ")
+			result, err := waitForUpstream(ctx, cd, transport, tracker, jobTemplate, jobData, webhookData.withMatrix(stageCombinations[i].Values))
 			if err != nil {
-				return nil, fmt.Errorf("failed to serialize job descriptor to JSON: %w", err)
+				fmt.Fprintf(stdout, "the upstream job %d of %s did not succeed: %v\n", jobData.JobID, jobTemplate, err)
+				failedTemplates[jobTemplate] = true
+				delete(results, jobTemplate)
+				continue
+			}
+			if results[jobTemplate] == nil {
+				results[jobTemplate] = result
 			}
 		}
+	}
+	return jobs, nil
+}
 
-		// Kick off the generated Job
-		startResp, err := transport.Start(context.Background(), *cd.Flags.FlagRequestor, string(jobDesc))
-		// If the server is not reachable
-		if err != nil {
-			return nil, fmt.Errorf("could not send the Job to the server: %w", err)
+// startJob renders the jobTemplate for the matrix combination, kicks off the job, sets the github commit
+// status to pending and registers the job for the job status tracking
+func startJob(ctx xcontext.Context, cd client.ClientDescriptor, transport transport.Transport, tracker client.JobTracker,
+	preHooks []*client.PreHookExecutionBundle, postHooks []*client.PostHookExecutionBundle, jobTemplate string,
+	combination client.MatrixCombination, webhookData WebhookData) (client.RunData, error) {
+	templateDescription, err := readJobTemplate(ctx, cd, jobTemplate, webhookData)
+	if err != nil {
+		return client.RunData{}, err
+	}
 
-			// If the server is reachable but something else went wrong
-		} else {
-			// If the job could not executed
-			if int(startResp.Data.JobID) == 0 {
-				return nil, fmt.Errorf("the Job could not executed. Server returned JobID 0")
-			}
-		}
+	// Adapt the jobDescriptor based on the webhookdata and convert it to JSON
+	vars := cd.TemplateVars(jobTemplate)
+	jobDesc, descriptor, err := RenderJobDescriptor(jobTemplate, templateDescription, webhookData.withMatrix(combination.Values), vars)
+	if err != nil {
+		return client.RunData{}, err
+	}
 
-		// Updating the github status to pending after the job is kicked off
-		Github := clientapi.GithubAPI{}
-		err = Github.EditGithubStatus(ctx, "pending", "http://www.urltotestreport.de/", jobName+". Test-Report:", webhookData.headSHA)
-		if err != nil {
-			return nil, fmt.Errorf("could not change the github status: %w", err)
+	// Retrieve the jobName for further usages, every matrix combination has its own jobName
+	jobName, err := descriptor.Name()
+	if err != nil {
+		return client.RunData{}, fmt.Errorf("could not retrieve the job name of %s: %w", jobTemplate, err)
+	}
+
+	// Let the PreJobExecutionHooks change or veto the job, the template might be rendered again
+	jobDesc, descriptor, err = runJobPreHooks(ctx, cd, preHooks, jobTemplate, jobName+combination.Suffix, templateDescription,
+		webhookData.withMatrix(combination.Values), jobDesc, descriptor, vars)
+	if err != nil {
+		return client.RunData{}, err
+	}
+	if jobName, err = descriptor.Name(); err != nil {
+		return client.RunData{}, fmt.Errorf("could not retrieve the job name of %s: %w", jobTemplate, err)
+	}
+	jobName += combination.Suffix
+	if combination.Suffix != "" {
+		if descriptor, err = descriptor.WithJobName(jobName); err != nil {
+			return client.RunData{}, err
+		}
+		if jobDesc, err = descriptor.JSON(); err != nil {
+			return client.RunData{}, err
 		}
+	}
 
-		// Filling the map with job data for postjobexecutionhooks
-		jobData := client.RunData{JobID: int(startResp.Data.JobID), JobName: jobName, JobSHA: webhookData.headSHA}
-		jobs = append(jobs, jobData)
+	// Kick off the generated Job
+	startResp, err := transport.Start(context.Background(), *cd.Flags.FlagRequestor, string(jobDesc))
+	// If the server is not reachable
+	if err != nil {
+		return client.RunData{}, &transientError{fmt.Errorf("could not send the Job to the server: %w", err)}
 
-		// Create Json Body for API Request to set a status for the started Job
-		data := map[string]interface{}{
-			"ID":     startResp.Data.JobID,
-			"Status": false,
+		// If the server is reachable but something else went wrong
+	} else {
+		// If the job could not executed
+		if int(startResp.Data.JobID) == 0 {
+			return client.RunData{}, fmt.Errorf("the Job could not executed. Server returned JobID 0")
 		}
+	}
 
-		// Marshal that data
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse data to json format: %w", err)
+	// Updating the commit status to running after the job is kicked off
+	err = clientapi.ActiveProviders().ReportStatus(ctx, webhookData.forge, clientapi.Status{Owner: webhookData.repoOwner,
+		Repo: webhookData.repoName, SHA: webhookData.headSHA, State: clientapi.StateRunning,
+		TargetURL: "http://www.urltotestreport.de/", Context: jobName + ". Test-Report:"})
+	if err != nil {
+		return client.RunData{}, fmt.Errorf("could not change the commit status: %w", err)
+	}
+
+	// Filling the map with job data for postjobexecutionhooks
+	jobData := client.RunData{JobID: int(startResp.Data.JobID), JobName: jobName, JobSHA: webhookData.headSHA, Template: jobTemplate,
+		Combination: combination.Suffix, RepoOwner: webhookData.repoOwner, RepoName: webhookData.repoName, Forge: webhookData.forge, Tags: descriptor.Tags, StepLabels: descriptor.StepLabels()}
+
+	// Let the PostJobExecutionHooks report the job right away, e.g. as a GitHub check run. The job keeps
+	// running if that fails, the hooks still see it when they run.
+	for _, bundle := range postHooks {
+		if hook, ok := bundle.PostJobExecutionHooks.(client.JobStartHook); ok {
+			if err := hook.JobStarted(ctx, bundle.Parameters, cd, &jobData); err != nil {
+				ctx.Warnf("could not report the start of the job %d: %v", jobData.JobID, err)
+			}
 		}
+	}
+
+	// Register the job for the job status tracking
+	if err := tracker.AddJob(ctx, jobData.JobID); err != nil {
+		return client.RunData{}, fmt.Errorf("could not track the status of the job: %w", err)
+	}
+	return jobData, nil
+}
 
-		// Add the job to the Api DB
-		addr := strings.Join([]string{*cd.Flags.FlagAddr, *cd.Flags.FlagPortAPI, "/addjobstatus/"}, "")
-		resp, err := http.Post(addr, "application/json", bytes.NewBuffer(jsonData))
+// RenderJobDescriptor substitutes the templates in the jobDescriptor with the webhook data and the
+// variables and converts it to JSON. The format is detected per file, see client.DetectYAML.
+// The rendered descriptor is parsed once, syntax and schema errors are reported with the file and the line of the template.
+func RenderJobDescriptor(file string, data []byte, webhookData WebhookData, vars map[string]interface{}) ([]byte, *client.JobDescriptor, error) {
+	YAML := client.DetectYAML(file, data)
+
+	// Adapt the jobDescriptor based on the webhookdata
+	jobDesc, lines, err := renderTemplate(data, webhookData, vars, YAML)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not change the job template %s: %w", file, err)
+	}
+
+	// If template file is JSON with comments or JSON5, convert it to plain JSON, the lines are kept
+	if !YAML {
+		jobDesc, err = client.NormalizeJSON(file, jobDesc)
 		if err != nil {
-			return nil, fmt.Errorf("could not post data to API: %w", err)
-		}
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("the HTTP Post responded a statuscode != 200 %w", err)
+			return nil, nil, lines.sourceError(err)
 		}
 	}
-	return jobs, nil
+	descriptor, err := client.DecodeJobDescriptor(file, jobDesc, YAML)
+	if err != nil {
+		return nil, nil, lines.sourceError(err)
+	}
+
+	// The rendered descriptor is sent to the ConTest server as JSON, with the fields the model does not know
+	jobDesc, err = descriptor.JSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	return jobDesc, descriptor, nil
 }
 
-// Parse the jobDescriptor and substitute all template with the webhook data
-func ChangeJobDescriptor(data []byte, webhookData WebhookData) ([]byte, error) {
-	// Create buffer to pass the adapted data
-	var buf bytes.Buffer
+// Parse the jobDescriptor and substitute all template with the webhook data and the variables.
+// The substituted values are escaped for the YAML or JSON descriptor, see escapeTemplate.
+func ChangeJobDescriptor(data []byte, webhookData WebhookData, vars map[string]interface{}, YAML bool) ([]byte, error) {
+	jobDesc, _, err := renderTemplate(data, webhookData, vars, YAML)
+	return jobDesc, err
+}
 
+// renderTemplate substitutes the templates like ChangeJobDescriptor and returns the lines of the job template
+// for the lines of the rendered descriptor
+func renderTemplate(data []byte, webhookData WebhookData, vars map[string]interface{}, YAML bool) ([]byte, *lineMap, error) {
 	// Convert data to a string that could be parsed
 	dataString := string(data)
 	// Create the data that should be substitute
-	jobDescData := templatedata{webhookData.headSHA}
+	jobDescData := webhookData.templateData(vars)
 	// Parse the file data
-	tmpl, err := template.New("jobDesc").Delims("[[", "]]").Parse(dataString)
+	tmpl, err := template.New("jobDesc").Delims("[[", "]]").Funcs(templateFuncs()).Funcs(escapeFuncs()).Parse(dataString)
 	if err != nil {
-		return buf.Bytes(), fmt.Errorf("parse the data for templates: %t", err)
+		return nil, nil, fmt.Errorf("parse the data for templates: %w", err)
 	}
-	// Substitute all templates with jobDescData and write it to buf
-	err = tmpl.Execute(&buf, jobDescData)
+	// Escape all values for the format of the descriptor
+	escapeTemplate(tmpl, YAML)
+	// Record the lines of the template while the output is written
+	lines := markLines(tmpl, dataString)
+	// Substitute all templates with jobDescData and write it to the output
+	err = tmpl.Execute(lines.out, jobDescData)
 	if err != nil {
-		return buf.Bytes(), fmt.Errorf("template substitution failed: %t", err)
+		return lines.out.Bytes(), lines, fmt.Errorf("template substitution failed: %w", err)
 	}
-	// Return buf as byte array
-	return buf.Bytes(), nil
+	return lines.out.Bytes(), lines, nil
 }
 
-// Unmarshal the data from the template file and retrieve the jobName
-func RetrieveJobName(data []byte, YAML bool) (string, error) {
-	// String interface{} map to unmarshal the incoming data
-	var jobDesc map[string]interface{}
+// RetrieveJobName retrieves the JobName of the job template without rendering it, e.g. to report a job
+// that is not started. The format is detected per file. A missing JobName is returned as error.
+func RetrieveJobName(file string, data []byte) (string, error) {
+	descriptor, err := client.ParseJobDescriptor(file, data)
+	if err != nil {
+		return "", err
+	}
+	return descriptor.Name()
+}
 
-	// Check if the file is YAML or JSON and unmarshal it
-	if !YAML {
-		// Retrieve the data and decode it into the jobDesc map
-		if err := json.Unmarshal(data, &jobDesc); err != nil {
-			return "", fmt.Errorf("failed to parse JSON job descriptor: %w", err)
+// runVerb executes the command that was passed on the command line against the ConTest server
+// and prints the response of the server as indented JSON
+func runVerb(ctx context.Context, cd client.ClientDescriptor, transport transport.Transport, stdout io.Writer) error {
+	verb := strings.ToLower(flagSet.Arg(0))
+	requestor := *cd.Flags.FlagRequestor
+
+	var (
+		resp interface{}
+		err  error
+	)
+	switch verb {
+	case "start":
+		// Read the jobDescriptor from the given file or from stdin, substitute the templates
+		// with the given SHA and variables and convert it to JSON
+		jobDesc, _, err := renderJobFile(cd, flagSet.Arg(1), WebhookData{headSHA: *flagSHA, matrix: flagMatrixValues()})
+		if err != nil {
+			return err
 		}
-	} else {
-		// Retrieve the data and decode it into the jobDesc map
-		if err := yaml.Unmarshal(data, &jobDesc); err != nil {
-			return "", fmt.Errorf("failed to parse YAML job descriptor: %w", err)
+		startResp, err := transport.Start(ctx, requestor, string(jobDesc))
+		if err != nil {
+			return fmt.Errorf("could not send the Job to the server: %w", err)
+		}
+		if !*flagWait && !*cd.Flags.FlagWait {
+			resp = startResp
+			break
+		}
+		// Print the start response before waiting for the job to finish
+		if err := printResponse(stdout, startResp); err != nil {
+			return err
+		}
+		if startResp.Data.JobID == 0 {
+			return fmt.Errorf("the Job could not executed. Server returned JobID 0")
+		}
+		jobID := int(startResp.Data.JobID)
+		tracker := client.NewJobTracker(cd, transport)
+		if err := tracker.AddJob(ctx, jobID); err != nil {
+			return fmt.Errorf("could not track the status of the job: %w", err)
+		}
+		statusResp, err := client.WaitForJob(ctx, tracker, transport, requestor, jobID, time.Duration(*cd.Flags.FlagjobWaitPoll)*time.Second)
+		if err != nil {
+			return err
+		}
+		if err := printResponse(stdout, statusResp); err != nil {
+			return err
+		}
+		if !client.JobSucceeded(statusResp.Data.Status) {
+			return fmt.Errorf("the Job %d did not complete successfully, state: %s", jobID, statusResp.Data.Status.State)
+		}
+		return nil
+	case "stop", "status", "retry":
+		jobID, err := parseJobID(flagSet.Arg(1))
+		if err != nil {
+			return err
 		}
+		switch verb {
+		case "stop":
+			resp, err = transport.Stop(ctx, requestor, jobID)
+		case "status":
+			resp, err = transport.Status(ctx, requestor, jobID)
+		case "retry":
+			resp, err = transport.Retry(ctx, requestor, jobID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not %s the Job %d: %w", verb, jobID, err)
+		}
+	case "list":
+		// Convert the state names into job states
+		var states []job.State
+		for _, stateName := range *flagStates {
+			state, err := job.EventNameToJobState(event.Name(stateName))
+			if err != nil {
+				return fmt.Errorf("invalid job state %q: %w", stateName, err)
+			}
+			states = append(states, state)
+		}
+		resp, err = transport.List(ctx, requestor, states, *flagTags)
+		if err != nil {
+			return fmt.Errorf("could not list the jobs: %w", err)
+		}
+	case "version":
+		resp, err = transport.Version(ctx, requestor)
+		if err != nil {
+			return fmt.Errorf("could not request the API version: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid command '%s', see --help", verb)
+	}
+
+	return printResponse(stdout, resp)
+}
+
+// readJobDescriptor reads the jobDescriptor from the given path or from stdin if the path is empty.
+// If the path does not exist, it is looked up in the descriptors/ directory
+func readJobDescriptor(path string) ([]byte, error) {
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "Reading from stdin...\n")
+		jobDesc, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the job descriptor from stdin: %w", err)
+		}
+		return jobDesc, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !filepath.IsAbs(path) {
+		path = filepath.Join("descriptors", path)
+	}
+	jobDesc, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the job descriptor: %w", err)
+	}
+	return jobDesc, nil
+}
+
+// parseJobID parses the job ID that was passed as argument
+func parseJobID(arg string) (types.JobID, error) {
+	if arg == "" {
+		return 0, fmt.Errorf("missing job ID")
+	}
+	jobID, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid job ID '%s': %w", arg, err)
+	}
+	if jobID == 0 {
+		return 0, fmt.Errorf("the job ID cannot be 0")
 	}
+	return types.JobID(jobID), nil
+}
 
-	// Retrieve the jobName from the JSON file
-	jobName := jobDesc["JobName"]
-	// Return the jobName
-	return jobName.(string), nil
+// printResponse prints the response of the server as indented JSON
+func printResponse(stdout io.Writer, resp interface{}) error {
+	indentedJSON, err := json.MarshalIndent(resp, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not serialize the response: %w", err)
+	}
+	fmt.Fprintf(stdout, "%s\n", indentedJSON)
+	return nil
 }
@@ -0,0 +1,150 @@
+package contestcli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/api"
+	"github.com/facebookincubator/contest/pkg/job"
+	"github.com/facebookincubator/contest/pkg/transport"
+	"github.com/facebookincubator/contest/pkg/types"
+	"github.com/facebookincubator/contest/pkg/xcontext"
+)
+
+// Test for RetrieveJobName, templates without a JobName string must not panic
+func TestRetrieveJobName(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"json", `{"JobName": "Build Test"}`, "Build Test", false},
+		{"json with comments", "// build\n{\"JobName\": \"Build Test\",}", "Build Test", false},
+		{"missing", `{"Runs": 1}`, "", true},
+		{"no string", `{"JobName": 42}`, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := RetrieveJobName("build.json", []byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// fakeTransport starts the jobs without a ConTest server and records their descriptors.
+// The jobs are completed in the state of states, JobStateCompleted by default.
+type fakeTransport struct {
+	transport.Transport
+	descriptors []string
+	states      map[types.JobID]string
+}
+
+func (f *fakeTransport) Start(ctx context.Context, requestor string, jobDescriptor string) (*api.StartResponse, error) {
+	f.descriptors = append(f.descriptors, jobDescriptor)
+	return &api.StartResponse{Data: api.ResponseDataStart{JobID: types.JobID(len(f.descriptors))}}, nil
+}
+
+func (f *fakeTransport) Status(ctx context.Context, requestor string, jobID types.JobID) (*api.StatusResponse, error) {
+	state, found := f.states[jobID]
+	if !found {
+		state = string(job.EventJobCompleted)
+	}
+	return &api.StatusResponse{Data: api.ResponseDataStatus{Status: &job.Status{State: state, JobReport: &job.JobReport{JobID: jobID}}}}, nil
+}
+
+// Test for run, if a job is started and reported for every combination of the matrix of the template
+func TestRunMatrix(t *testing.T) {
+	dir := t.TempDir()
+	template := `{
+    "JobName": "Boot [[ .Matrix.Payload ]]",
+    "Runs": 1,
+    "TestDescriptors": [{
+        "TargetManagerName": "TargetList",
+        "TargetManagerAcquireParameters": {},
+        "TestFetcherName": "literal",
+        "TestFetcherFetchParameters": {"TestName": "boot", "Steps": [{"name": "cmd", "label": "boot", "parameters": {}}]}
+    }],
+    "Reporting": {"RunReporters": [{"name": "TargetSuccess"}]}
+}`
+	if err := os.WriteFile(filepath.Join(dir, "boot.json"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reporter := &fakeReporter{}
+	clientapi.SetProviders(&clientapi.Providers{Reporters: map[string]clientapi.StatusReporter{clientapi.ForgeGithub: reporter}})
+	defer clientapi.SetProviders(nil)
+
+	requestor := "9e-contestcli"
+	cd := client.ClientDescriptor{
+		Flags:           client.Flags{FlagRequestor: &requestor},
+		TemplateSources: []client.TemplateSource{{Dir: dir}},
+		Templates: map[string]client.TemplateConfig{
+			"boot.json": {Matrix: map[string][]interface{}{"Payload": {"linuxboot", "tianocore"}}},
+		},
+	}
+	webhookData := WebhookData{forge: clientapi.ForgeGithub, repoOwner: "9elements", repoName: "coreboot",
+		headSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", templates: []string{"boot.json"}}
+	fake := &fakeTransport{}
+
+	jobs, err := run(xcontext.Background(), cd, fake, ioutil.Discard, webhookData, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := []string{"Boot linuxboot (Payload=linuxboot)", "Boot tianocore (Payload=tianocore)"}
+	var names, started, contexts []string
+	for _, jobData := range jobs {
+		names = append(names, jobData.JobName)
+	}
+	for _, jobDesc := range fake.descriptors {
+		var descriptor struct{ JobName string }
+		if err := json.Unmarshal([]byte(jobDesc), &descriptor); err != nil {
+			t.Fatalf("got invalid JSON %s: %v", jobDesc, err)
+		}
+		started = append(started, descriptor.JobName)
+	}
+	for _, status := range reporter.statuses {
+		if status.State != clientapi.StateRunning {
+			t.Errorf("got %+v want the job as running", status)
+		}
+		contexts = append(contexts, strings.TrimSuffix(status.Context, ". Test-Report:"))
+	}
+	if !reflect.DeepEqual(names, want) || !reflect.DeepEqual(started, want) || !reflect.DeepEqual(contexts, want) {
+		t.Errorf("got jobs %v, started %v and statuses %v want %v", names, started, contexts, want)
+	}
+}
+
+// Test for RenderJobDescriptor, if the errors are reported with the lines of the job template
+// although the range loop adds lines to the rendered descriptor
+func TestRenderJobDescriptorLine(t *testing.T) {
+	data := `{
+    // Tag the job with the changed files
+    JobName: 'Build Test',
+    Tags: [
+[[- range .ChangedFiles ]]
+        "[[ . ]]",
+[[- end ]]
+    ],
+    Runs: "two",
+}`
+	webhookData := WebhookData{changedFiles: []string{"Makefile.inc", "src/mainboard/romstage.c", "src/soc/intel/xeon_sp/spr/chip.c",
+		"src/soc/intel/xeon_sp/spr/romstage.c", "src/soc/intel/xeon_sp/spr/soc_util.c"}}
+	_, _, err := RenderJobDescriptor("build.json", []byte(data), webhookData, nil)
+	want := "build.json:9: cannot unmarshal string into Go struct field JobDescriptor.Runs of type uint"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v want %q", err, want)
+	}
+}
@@ -1,75 +1,293 @@
 package contestcli
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"time"
 
-	"github.com/google/go-github/github"
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/9elements/contest-client/pkg/store"
+	"github.com/facebookincubator/contest/pkg/xcontext"
 )
 
 type WebhookData struct {
-	headSHA string
-	sshURL  string
-	refSHA  string
+	forge        string
+	deliveryID   string
+	event        string
+	headSHA      string
+	baseSHA      string
+	sshURL       string
+	cloneURL     string
+	baseSSHURL   string
+	baseCloneURL string
+	refSHA       string
+	ref          string
+	baseRef      string
+	repoOwner    string
+	repoName     string
+	prNumber     int
+	patchSet     int
+	title        string
+	author       string
+	changedFiles []string
+	command      *clientapi.Command // Slash command of a comment on the pull request, nil for other webhooks
+
+	// Finished upstream jobs and the matrix combination of the job, they are only
+	// known while the pipeline runs and not persisted
+	upstream map[string]upstreamJob
+	matrix   map[string]interface{}
+	// Job templates selected by the command, they replace the templates of the routes if they are set
+	templates []string
+}
+
+// webhookDataJSON is the serialized form of WebhookData that is persisted in the state store
+type webhookDataJSON struct {
+	Forge        string `json:",omitempty"`
+	DeliveryID   string
+	Event        string
+	HeadSHA      string
+	BaseSHA      string
+	SSHURL       string
+	CloneURL     string
+	BaseSSHURL   string
+	BaseCloneURL string
+	RefSHA       string
+	Ref          string
+	BaseRef      string
+	RepoOwner    string
+	RepoName     string
+	PRNumber     int
+	PatchSet     int `json:",omitempty"`
+	Title        string
+	Author       string
+	ChangedFiles []string
+	Command      *clientapi.Command `json:",omitempty"`
+}
+
+// MarshalJSON serializes the webhook data to persist it in the state store
+func (webhookdata WebhookData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(webhookDataJSON{
+		Forge:        webhookdata.forge,
+		DeliveryID:   webhookdata.deliveryID,
+		Event:        webhookdata.event,
+		HeadSHA:      webhookdata.headSHA,
+		BaseSHA:      webhookdata.baseSHA,
+		SSHURL:       webhookdata.sshURL,
+		CloneURL:     webhookdata.cloneURL,
+		BaseSSHURL:   webhookdata.baseSSHURL,
+		BaseCloneURL: webhookdata.baseCloneURL,
+		RefSHA:       webhookdata.refSHA,
+		Ref:          webhookdata.ref,
+		BaseRef:      webhookdata.baseRef,
+		RepoOwner:    webhookdata.repoOwner,
+		RepoName:     webhookdata.repoName,
+		PRNumber:     webhookdata.prNumber,
+		PatchSet:     webhookdata.patchSet,
+		Title:        webhookdata.title,
+		Author:       webhookdata.author,
+		ChangedFiles: webhookdata.changedFiles,
+		Command:      webhookdata.command,
+	})
+}
+
+// UnmarshalJSON restores the webhook data from the state store
+func (webhookdata *WebhookData) UnmarshalJSON(data []byte) error {
+	var w webhookDataJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	webhookdata.forge = w.Forge
+	webhookdata.deliveryID = w.DeliveryID
+	webhookdata.event = w.Event
+	webhookdata.headSHA = w.HeadSHA
+	webhookdata.baseSHA = w.BaseSHA
+	webhookdata.sshURL = w.SSHURL
+	webhookdata.cloneURL = w.CloneURL
+	webhookdata.baseSSHURL = w.BaseSSHURL
+	webhookdata.baseCloneURL = w.BaseCloneURL
+	webhookdata.refSHA = w.RefSHA
+	webhookdata.ref = w.Ref
+	webhookdata.baseRef = w.BaseRef
+	webhookdata.repoOwner = w.RepoOwner
+	webhookdata.repoName = w.RepoName
+	webhookdata.prNumber = w.PRNumber
+	webhookdata.patchSet = w.PatchSet
+	webhookdata.title = w.Title
+	webhookdata.author = w.Author
+	webhookdata.changedFiles = w.ChangedFiles
+	webhookdata.command = w.Command
+	return nil
+}
+
+// branch identifies the branch of the repository the webhook was triggered for.
+// Jobs of older commits on the same branch are superseded by the jobs of this webhook.
+func (webhookdata WebhookData) branch() string {
+	return webhookdata.sshURL + "#" + webhookdata.refSHA
+}
+
+// trustedHead returns true if the head commit was pushed to the repository itself by somebody with write access.
+// Pull requests of forks and Gerrit patchsets can be uploaded by anybody who may open them.
+func (webhookdata WebhookData) trustedHead() bool {
+	return webhookdata.forge != clientapi.ForgeGerrit && webhookdata.sshURL == webhookdata.baseSSHURL
+}
+
+// fullName returns "owner/name" of the repository, Gerrit projects may have no owner
+func (webhookdata WebhookData) fullName() string {
+	if webhookdata.repoOwner == "" {
+		return webhookdata.repoName
+	}
+	return webhookdata.repoOwner + "/" + webhookdata.repoName
 }
+
+// clientEvent returns the properties of the webhook that select the job templates and that are passed to the JobPreHooks
+func (webhookdata WebhookData) clientEvent() client.Event {
+	return client.Event{
+		Repository:   webhookdata.fullName(),
+		Branch:       webhookdata.baseRef,
+		Type:         webhookdata.event,
+		ChangedFiles: webhookdata.changedFiles,
+		DeliveryID:   webhookdata.deliveryID,
+		SHA:          webhookdata.headSHA,
+		BaseSHA:      webhookdata.baseSHA,
+		Ref:          webhookdata.ref,
+		PRNumber:     webhookdata.prNumber,
+		PatchSet:     webhookdata.patchSet,
+		Title:        webhookdata.title,
+		Author:       webhookdata.author,
+	}
+}
+
 type Channel struct {
 	webhookdata chan WebhookData
+	store       *store.Store
+	gerrit      client.Gerrit
+	providers   *clientapi.Providers
 }
 
-func webhook(webhookData chan WebhookData) {
+// webhook starts the webhook listener that is configured in the listener descriptor and the
+// event stream of Gerrit, if one is configured
+func webhook(ctx xcontext.Context, cd client.ClientDescriptor, providers *clientapi.Providers, webhookData chan WebhookData, store *store.Store) error {
+	listener, gerrit := cd.Listener, cd.Gerrit
 	// Start webhook listener
-	channel := &Channel{webhookdata: webhookData}
-	log.Println("webhook listener is running and running")
-	http.HandleFunc("/", channel.handleWebhook)
-	err := http.ListenAndServeTLS("0.0.0.0:6000", "/certs/fullchain.crt", "/certs/server.key", nil)
-	if err != nil {
-		log.Printf("error listening to the webhook, err: %s\n", err)
+	channel := &Channel{webhookdata: webhookData, store: store, gerrit: gerrit, providers: providers}
+	mux := http.NewServeMux()
+	mux.HandleFunc(listener.Path, channel.handleWebhook)
+	// Gerrit may deliver its webhooks to the path of the other forges, it is only registered once
+	if gerrit.WebhookPath != "" && gerrit.WebhookPath != listener.Path {
+		mux.HandleFunc(gerrit.WebhookPath, channel.handleWebhook)
 	}
+	if gerrit.SSH != "" {
+		go channel.streamGerritEvents(ctx)
+	}
+	server := &http.Server{Addr: listener.Addr, Handler: mux}
+
+	// Plain HTTP is used if the TLS termination is done by a reverse proxy
+	if listener.PlainHTTP {
+		ctx.Infof("webhook listener is running on http://%s%s", listener.Addr, listener.Path)
+		return server.ListenAndServe()
+	}
+	ctx.Infof("webhook listener is running on https://%s%s", listener.Addr, listener.Path)
+	return server.ListenAndServeTLS(listener.CertFile, listener.KeyFile)
 }
 
-// HandleWebhook handles incoming webhooks
+// HandleWebhook handles incoming webhooks, the first active trigger that matches the webhook parses it
 func (channel *Channel) handleWebhook(w http.ResponseWriter, r *http.Request) {
-	//retrieve the github_secret for the webhook from .env
-	github_secret := os.Getenv("GITHUB_SECRET")
-	// Receiving and validating the incoming webhook
-	payload, err := github.ValidatePayload(r, []byte(github_secret))
+	trigger := channel.providers.Trigger(r)
+	if trigger == nil {
+		log.Printf("received a webhook of no active forge on %s\n", r.URL.Path)
+		http.Error(w, "unknown webhook", http.StatusBadRequest)
+		return
+	}
+	event, err := trigger.Parse(r)
 	if err != nil {
-		log.Printf("error reading request body, err: %s\n", err)
+		log.Printf("could not handle incoming webhook: %v\n", err)
+		code, msg := http.StatusBadRequest, "could not parse webhook"
+		var reqErr *clientapi.RequestError
+		if errors.As(err, &reqErr) {
+			code, msg = reqErr.Code, reqErr.Msg
+		}
+		http.Error(w, msg, code)
+		return
+	}
+	// Events that do not start jobs are acknowledged
+	if event == nil {
 		return
 	}
-	defer r.Body.Close()
+	channel.enqueue(w, eventData(event))
+}
 
-	// Parsing the incoming webhook
-	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+// eventData returns the webhook data of the normalized event of a trigger
+func eventData(e *clientapi.Event) WebhookData {
+	return WebhookData{
+		forge:        e.Forge,
+		deliveryID:   e.DeliveryID,
+		event:        e.Type,
+		headSHA:      e.SHA,
+		baseSHA:      e.BaseSHA,
+		sshURL:       e.SSHURL,
+		cloneURL:     e.CloneURL,
+		baseSSHURL:   e.BaseSSHURL,
+		baseCloneURL: e.BaseCloneURL,
+		refSHA:       e.Branch,
+		ref:          e.Ref,
+		baseRef:      e.BaseBranch,
+		repoOwner:    e.RepoOwner,
+		repoName:     e.RepoName,
+		prNumber:     e.PRNumber,
+		patchSet:     e.PatchSet,
+		title:        e.Title,
+		author:       e.Author,
+		changedFiles: e.ChangedFiles,
+		command:      e.Command,
+	}
+}
+
+// enqueue records the delivery in the state store and passes the webhookdata to the workers
+// without blocking the handler. The webhook is answered with 202 Accepted right away, the jobs
+// run in the background.
+func (channel *Channel) enqueue(w http.ResponseWriter, webhookdata WebhookData) {
+	status, err := channel.queue(webhookdata)
 	if err != nil {
-		log.Printf("could not parse incoming webhook: %v\n", err)
+		http.Error(w, err.Error(), status)
 		return
 	}
+	w.WriteHeader(status)
+}
 
-	// Switch to handle the different eventtypes of the webhook
-	switch e := event.(type) {
-	case *github.PullRequestEvent:
-		if *e.Action == "synchronize" || *e.Action == "closed" {
-			return
-		}
-		fmt.Printf("successful received pullrequest event\n")
-		// Assign 1.the HEAD SHA, 2.the SSH link, 3.the Reference SHA and than pass it to the channel
-		var webhookdata WebhookData
-		webhookdata.headSHA = *e.PullRequest.Head.SHA
-		webhookdata.sshURL = *e.PullRequest.Head.Repo.SSHURL
-		webhookdata.refSHA = *e.PullRequest.Head.Ref
-		channel.webhookdata <- webhookdata
-	case *github.PushEvent:
-		// Assign 1.the commmit SHA, 2.the SSH link and than pass it to the channel
-		fmt.Printf("successful received push event\n")
-		var webhookdata WebhookData
-		webhookdata.headSHA = *e.After
-		webhookdata.sshURL = *e.Repo.SSHURL
-		channel.webhookdata <- webhookdata
+// queue records the delivery in the state store and passes the webhookdata to the workers without
+// blocking. It returns the HTTP status the delivery is answered with.
+func (channel *Channel) queue(webhookdata WebhookData) (int, error) {
+	if webhookdata.deliveryID == "" {
+		webhookdata.deliveryID = fmt.Sprintf("%d-%s", time.Now().UnixNano(), webhookdata.headSHA)
+	}
+	webhook, err := json.Marshal(webhookdata)
+	if err != nil {
+		log.Printf("could not serialize the webhook data: %v\n", err)
+		return http.StatusInternalServerError, fmt.Errorf("could not store webhook")
+	}
+	added, err := channel.store.AddDelivery(webhookdata.deliveryID, webhook)
+	if err != nil {
+		log.Printf("could not store the delivery %s: %v\n", webhookdata.deliveryID, err)
+		return http.StatusInternalServerError, fmt.Errorf("could not store webhook")
+	}
+	// GitHub redelivers webhooks, every delivery is only processed once
+	if !added {
+		log.Printf("the delivery %s was already received\n", webhookdata.deliveryID)
+		return http.StatusOK, nil
+	}
+
+	select {
+	case channel.webhookdata <- webhookdata:
+		return http.StatusAccepted, nil
 	default:
-		log.Printf("successful received unknown event %s %s\n", github.WebHookType(r), e)
-		return
+		log.Printf("the webhook queue is full, dropping the event for commit %s\n", webhookdata.headSHA)
+		if err := channel.store.RemoveDelivery(webhookdata.deliveryID); err != nil {
+			log.Printf("could not remove the delivery %s: %v\n", webhookdata.deliveryID, err)
+		}
+		return http.StatusServiceUnavailable, fmt.Errorf("too many queued webhooks")
 	}
 }
@@ -16,14 +16,32 @@ import (
 // Requires the `httplistener` plugin for the API listener.
 //
 // Usage examples:
+// Listen for webhooks and run the configured job templates:
+//   ./contestcli serve -c clientconfig.json
+//
+// Start a job from the descriptors/ directory and wait for it to finish:
+//   ./contestcli start coreboot-spr-sp_build-test.yaml --sha <commit> --wait
+//
+// Start a job on other hosts by overriding a template variable:
+//   ./contestcli start coreboot-spr-sp_qemu-boot-test.yaml --var HostPrefix=tp-slot
+//
+// Check the job descriptors without a ConTest server, e.g. in a pre-commit hook:
+//   ./contestcli validate descriptors/*.yaml --sha <commit>
+//
+// Print the job descriptor that a pull request webhook would start:
+//   ./contestcli render coreboot-spr-sp_build-test.yaml --payload pull_request.json
+//
+// Get the status of the job with ID 42:
+//   ./contestcli status 42
+//
 // List all the jobs:
 //   ./contestcli list
 //
 // List all the failed jobs:
-//   ./contestcli list -state JobStateFailed
+//   ./contestcli list --states JobStateFailed
 //
 // List all the failed jobs with tags "foo" and "bar":
-//   ./contestcli list -state JobStateFailed -tags foo,bar
+//   ./contestcli list --states JobStateFailed --tags foo,bar
 
 func main() {
 	if err := contestcli.CLIMain(os.Args[0], os.Args[1:], os.Stdout); err != nil {
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
+	"os"
+	"strings"
 
 	"github.com/facebookincubator/contest/pkg/transport"
 )
@@ -21,10 +24,35 @@ type PostJobExecutionHookLoader func() (string, PostJobExecutionHooksFactory)
 // input to the client at start.
 type ClientDescriptor struct {
 	Flags                 Flags
+	Listener              Listener
+	GithubApp             GithubApp
+	Gitlab                Gitlab
+	Gerrit                Gerrit
+	Gitea                 []Gitea                           // Gitea and Forgejo instances, selected per repository
+	Providers             Providers                         // Forges and notifiers that are active
+	Variables             map[string]interface{}            // Variables that are exposed to all job templates as .Vars
+	TemplateVariables     map[string]map[string]interface{} // Variables per job template file name, they override Variables
+	Routes                []Route                           // Select the job templates per webhook, FlagJobTemplate is used without routes
+	Templates             map[string]TemplateConfig         // Dependencies and outputs per job template file name
+	TemplateSources       []TemplateSource                  // Sources the job templates are loaded from, the first source that has the template is used
+	Validation            Validation                        // Plugins of the ConTest server the job descriptors are validated against
 	PreJobExecutionHooks  []*PreHookDescriptor
 	PostJobExecutionHooks []*PostHookDescriptor
 }
 
+// TemplateVars returns the variables of the job template. The variables of the template
+// override the global variables with the same name.
+func (cd ClientDescriptor) TemplateVars(template string) map[string]interface{} {
+	vars := make(map[string]interface{})
+	for name, value := range cd.Variables {
+		vars[name] = value
+	}
+	for name, value := range cd.TemplateVariables[template] {
+		vars[name] = value
+	}
+	return vars
+}
+
 // ExecutionHookParam represents a ExecutionHook parameter. It is initialized from JSON,
 // and can be a string or a more complex JSON structure.
 // ExecutionHookPlugins are expected to know which one they expect and use the
@@ -43,15 +71,120 @@ type Flags struct {
 	// Flag-related parameters
 	FlagAddr        *string   //ConTest server [scheme://]host to connect to
 	FlagPortServer  *string   //Port that the server is using ":port"
-	FlagPortAPI     *string   //Port that the API is using ":port"
+	FlagPortAPI     *string   //Port that the job status API is using ":port", only used with FlagStatusAPI
 	FlagRequestor   *string   //Identifier of the requestor of the API call
 	FlagWait        *bool     //After starting a job, wait for it to finish, and exit 0 only if it is successful
-	FlagYAML        *bool     //JSON or YAML
+	FlagYAML        *bool     //Deprecated: the format of the job templates is detected per file
 	FlagS3          *bool     //Upload Job Result to S3 Bucket
 	FlagjobWaitPoll *int      //Time in seconds for the interval requesting the job status
+	FlagStatusAPI   *bool     //Track the job status with the external job status API instead of the ConTest server
+	FlagWorkers     *int      //Number of webhooks that are processed concurrently, default 4
+	FlagStateFile   *string   //File the state of the webhook deliveries is stored in, default contestcli-state.json
 	FlagLogLevel    *string   //possible values: debug, info, warning, error, panic, fatal
-	FlagJobTemplate []*string //filenames to the job templates, no default
+	FlagJobTemplate []*string //filenames to the job templates that are started if no Routes are configured, no default
+}
+
+// Listener describes where the webhook listener of the serve command is reachable
+type Listener struct {
+	Addr      string // Address the listener binds to "host:port", default 0.0.0.0:6000
+	CertFile  string // Path to the TLS certificate, default /certs/fullchain.crt
+	KeyFile   string // Path to the TLS private key, default /certs/server.key
+	PlainHTTP bool   // Serve plain HTTP instead of HTTPS, e.g. behind a reverse proxy
+	Path      string // URL path the webhooks are received on, default /
+}
+
+// TemplateSource describes where the job templates are loaded from. Exactly one of the fields is set.
+// Templates run any command on the targets, RepoPath is therefore opt-in: its templates are only loaded for
+// commits that were pushed to the repository itself, which needs write access. Pull requests of forks and
+// Gerrit patchsets, which anybody can upload, fall through to the next source unless AllowForks is set.
+type TemplateSource struct {
+	Dir        string // Local directory, relative to the working directory, default descriptors
+	URL        string // HTTP(S) base URL, the file name of the template is appended
+	RepoPath   string // Directory in the repository that triggered the webhook, loaded at the pushed SHA
+	AllowForks bool   // Load templates of RepoPath from pull requests of forks and Gerrit patchsets, they can run any command on the targets
+}
+
+// GithubApp describes the GitHub App the client authenticates as. If no app is configured,
+// the personal access token in the GITHUB_TOKEN env variable is used.
+type GithubApp struct {
+	AppID          int64  // ID of the GitHub App
+	PrivateKeyFile string // Path to the PEM encoded private key of the GitHub App
+}
+
+// Gitlab describes the self-hosted GitLab instance webhooks are received from. The access token is read
+// from the GITLAB_TOKEN env variable and the secret token of the webhooks from GITLAB_SECRET.
+type Gitlab struct {
+	URL string // Base URL of GitLab, default https://gitlab.com
+}
+
+// Gerrit describes the Gerrit instance patchset-created events are received from, over SSH with stream-events
+// or from the webhooks plugin. The account that votes on the patchsets is read from the GERRIT_USERNAME and
+// GERRIT_PASSWORD (HTTP password) env variables, the token of the webhooks from GERRIT_SECRET.
+type Gerrit struct {
+	URL         string // Base URL of Gerrit, e.g. https://review.coreboot.org
+	SSH         string // "user@host:port" the events are streamed from with stream-events, no stream if empty
+	WebhookPath string // URL path the webhooks plugin posts to with ?token=GERRIT_SECRET, no webhooks if empty
+	Label       string // Label that is voted on, default Verified
+}
+
+// SSHAddress splits "user@host:port" of the SSH daemon of Gerrit, the port defaults to 29418
+func (g Gerrit) SSHAddress() (string, string, error) {
+	if g.SSH == "" {
+		return "", "", errors.New("the SSH address of gerrit is not configured")
+	}
+	userHost, port, err := net.SplitHostPort(g.SSH)
+	if err != nil {
+		return g.SSH, "29418", nil
+	}
+	return userHost, port, nil
+}
+
+// Providers selects the forges the webhooks are received from and the statuses are reported to,
+// and the notifiers of the finished jobs
+type Providers struct {
+	Forges    []string // "github", "gitlab", "gerrit" or "gitea", all if empty
+	Notifiers []string // "slack", Slack if it is not set, none if it is empty
+}
+
+// Gitea describes a Gitea or Forgejo instance and the repositories it hosts. The webhooks are validated with the
+// HMAC secret in the SecretEnv env variable, the commit statuses are set with the access token in TokenEnv.
+type Gitea struct {
+	URL          string   // Base URL of Gitea, e.g. https://git.example.com
+	Repositories []string // Globs of the "owner/name" of the repositories hosted on the instance, all if empty
+	TokenEnv     string   // Env variable of the access token, default GITEA_TOKEN
+	SecretEnv    string   // Env variable of the secret of the webhooks, default GITEA_SECRET
 }
+
+// Token returns the access token of the Gitea instance
+func (g Gitea) Token() string {
+	if g.TokenEnv == "" {
+		return os.Getenv("GITEA_TOKEN")
+	}
+	return os.Getenv(g.TokenEnv)
+}
+
+// Secret returns the secret of the webhooks of the Gitea instance
+func (g Gitea) Secret() string {
+	if g.SecretEnv == "" {
+		return os.Getenv("GITEA_SECRET")
+	}
+	return os.Getenv(g.SecretEnv)
+}
+
+// GiteaFor returns the first Gitea instance that hosts the repository "owner/name". If the web URL of the
+// repository is known, only the instances it belongs to are considered.
+func GiteaFor(instances []Gitea, repository string, repoURL string) (Gitea, bool) {
+	for _, instance := range instances {
+		if repoURL != "" && !strings.HasPrefix(repoURL, strings.TrimSuffix(instance.URL, "/")+"/") {
+			continue
+		}
+		if len(instance.Repositories) == 0 || matchAny(instance.Repositories, repository) {
+			return instance, true
+		}
+	}
+	return Gitea{}, false
+}
+
 type PreHookDescriptor struct {
 	// PreJobExecutionHook-related parameters
 	Name       string
@@ -78,9 +211,17 @@ type PostHookExecutionBundle struct {
 
 // RunData cointains data that can be used to hand over data through the program flow
 type RunData struct {
-	JobID   int
-	JobName string
-	JobSHA  string
+	JobID       int
+	JobName     string
+	JobSHA      string
+	Template    string   // File name of the job template the job was rendered from
+	Combination string   // Suffix of the matrix combination of the job, empty if the template has no matrix
+	RepoOwner   string   // Owner of the repository the job was triggered for
+	RepoName    string   // Name of the repository the job was triggered for
+	Forge       string   // Forge the job was triggered from, e.g. gitlab, empty for github
+	Tags        []string // Tags of the job descriptor
+	StepLabels  []string // Labels of the test steps of the job descriptor
+	CheckRunID  int64    // ID of the GitHub check run of the job, 0 if there is none
 }
 
 // PreValidate performs sanity check on the PreExecutionHookContent
@@ -110,3 +251,10 @@ type PostJobExecutionHooks interface {
 		transport transport.Transport, rundata []RunData) (interface{}, error)
 	ValidateParameters([]byte) (interface{}, error)
 }
+
+// JobStartHook is implemented by the PostJobExecutionHooks that report a job as soon as it was started.
+// JobStarted is called for every job after the ConTest server accepted it. The hook can record its
+// references in the RunData, e.g. CheckRunID, they are stored with the job and handed to Run after a restart.
+type JobStartHook interface {
+	JobStarted(ctx context.Context, parameters interface{}, clientDescriptor ClientDescriptor, job *RunData) error
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/icza/dyno"
+	"gopkg.in/yaml.v2"
+)
+
+// JobDescriptor is the typed model of a job descriptor. It is parsed once after the job
+// template was rendered, so that the name, the tags and the step labels are available
+// without decoding the raw descriptor again. The model is only read, the rendered descriptor
+// is sent to the ConTest server with all its fields, see JSON.
+type JobDescriptor struct {
+	JobName         string           `yaml:"JobName"`
+	Runs            uint             `yaml:"Runs"`
+	RunInterval     string           `yaml:"RunInterval" json:",omitempty"`
+	Tags            []string         `yaml:"Tags" json:",omitempty"`
+	TestDescriptors []TestDescriptor `yaml:"TestDescriptors"`
+	Reporting       *Reporting       `yaml:"Reporting"`
+
+	raw []byte // Rendered descriptor as JSON, including the fields that are not modelled
+}
+
+// TestDescriptor describes the targets and the test steps of a job
+type TestDescriptor struct {
+	TargetManagerName              string                 `yaml:"TargetManagerName"`
+	TargetManagerAcquireParameters map[string]interface{} `yaml:"TargetManagerAcquireParameters"`
+	TargetManagerReleaseParameters map[string]interface{} `yaml:"TargetManagerReleaseParameters"`
+	TestFetcherName                string                 `yaml:"TestFetcherName"`
+	TestFetcherFetchParameters     *FetchParameters       `yaml:"TestFetcherFetchParameters"`
+}
+
+// FetchParameters are the parameters of the literal and the URI test fetcher
+type FetchParameters struct {
+	TestName string `yaml:"TestName"`
+	URI      string `yaml:"URI" json:",omitempty"`
+	Steps    []Step `yaml:"Steps" json:",omitempty"`
+}
+
+// Step is a test step of a literal test, every parameter is a list of values
+type Step struct {
+	Name       string                 `yaml:"name" json:"name"`
+	Label      string                 `yaml:"label" json:"label"`
+	Parameters map[string]interface{} `yaml:"parameters" json:"parameters"`
+}
+
+// Reporting describes the reporters of a job
+type Reporting struct {
+	RunReporters   []Reporter `yaml:"RunReporters"`
+	FinalReporters []Reporter `yaml:"FinalReporters" json:",omitempty"`
+}
+
+// Reporter is a run or final reporter with its parameters
+type Reporter struct {
+	Name       string      `yaml:"name" json:"name"`
+	Parameters interface{} `yaml:"parameters" json:"parameters"`
+}
+
+// ParseJobDescriptor parses the rendered job descriptor file, the format is detected per file.
+// Syntax and type errors are returned as DescriptorError with their lines.
+func ParseJobDescriptor(file string, data []byte) (*JobDescriptor, error) {
+	YAML := DetectYAML(file, data)
+	if !YAML {
+		normalized, err := NormalizeJSON(file, data)
+		if err != nil {
+			return nil, err
+		}
+		data = normalized
+	}
+	return DecodeJobDescriptor(file, data, YAML)
+}
+
+// DecodeJobDescriptor decodes the YAML or plain JSON job descriptor with the parser of its format,
+// so that syntax and type errors are returned as DescriptorError with their lines
+func DecodeJobDescriptor(file string, data []byte, YAML bool) (*JobDescriptor, error) {
+	var desc JobDescriptor
+	if !YAML {
+		if err := json.Unmarshal(data, &desc); err != nil {
+			return nil, jsonError(file, data, err)
+		}
+		desc.raw = data
+		return &desc, nil
+	}
+
+	// The YAML parser reports the syntax and type errors with their lines
+	if err := yaml.Unmarshal(data, &desc); err != nil {
+		return nil, yamlError(file, err)
+	}
+	// ConTest matches the field names case-insensitively, like the JSON parser does, e.g. steps
+	// instead of Steps. The descriptor is decoded again from its JSON form.
+	var body interface{}
+	if err := yaml.Unmarshal(data, &body); err != nil {
+		return nil, yamlError(file, err)
+	}
+	jsonData, err := json.MarshalIndent(dyno.ConvertMapI2MapS(body), "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize job descriptor to JSON: %w", err)
+	}
+	desc = JobDescriptor{}
+	if err := json.Unmarshal(jsonData, &desc); err != nil {
+		return nil, jsonError(file, nil, err)
+	}
+	desc.raw = jsonData
+	return &desc, nil
+}
+
+// JSON returns the rendered job descriptor as JSON for the ConTest server. The fields that are not
+// part of the model, e.g. the parameters of other test fetchers, are sent unchanged.
+func (d *JobDescriptor) JSON() ([]byte, error) {
+	if d.raw == nil {
+		return nil, fmt.Errorf("the job descriptor %q was not decoded from a rendered descriptor", d.JobName)
+	}
+	return d.raw, nil
+}
+
+// WithJobName returns a copy of the job descriptor with the JobName, e.g. with the suffix of the matrix combination
+func (d *JobDescriptor) WithJobName(name string) (*JobDescriptor, error) {
+	named := *d
+	named.JobName = name
+	err := named.editRaw(func(body map[string]interface{}) {
+		body[rawKey(body, "JobName")] = name
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &named, nil
+}
+
+// editRaw applies the change to the decoded rendered descriptor and encodes it again
+func (d *JobDescriptor) editRaw(change func(body map[string]interface{})) error {
+	if d.raw == nil {
+		return fmt.Errorf("the job descriptor %q was not decoded from a rendered descriptor", d.JobName)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(d.raw))
+	// Numbers are kept as they were written, e.g. large integers
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode the job descriptor: %w", err)
+	}
+	change(body)
+	raw, err := json.MarshalIndent(body, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize job descriptor to JSON: %w", err)
+	}
+	d.raw = raw
+	return nil
+}
+
+// rawKey returns the key of the field in the decoded descriptor, ConTest matches the field names
+// case-insensitively. The name is returned if the field is missing.
+func rawKey(obj map[string]interface{}, name string) string {
+	if _, found := obj[name]; found {
+		return name
+	}
+	for key := range obj {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// Name returns the JobName, it is an error if the descriptor has none
+func (d *JobDescriptor) Name() (string, error) {
+	if d.JobName == "" {
+		return "", fmt.Errorf("the job descriptor has no JobName")
+	}
+	return d.JobName, nil
+}
+
+// StepLabels returns the labels of the test steps of all literal test descriptors, in their order
+func (d *JobDescriptor) StepLabels() []string {
+	var labels []string
+	for _, td := range d.TestDescriptors {
+		if td.TestFetcherFetchParameters == nil {
+			continue
+		}
+		for _, step := range td.TestFetcherFetchParameters.Steps {
+			if step.Label != "" {
+				labels = append(labels, step.Label)
+			}
+		}
+	}
+	return labels
+}
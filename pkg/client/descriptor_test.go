@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJobDescriptor(t *testing.T) {
+	data := `{
+    // Build test of coreboot
+    "JobName": "Build Test",
+    "Runs": 1,
+    "Tags": ["coreboot", "build"],
+    "TestDescriptors": [{
+        "TargetManagerName": "TargetList",
+        "TestFetcherName": "literal",
+        "TestFetcherFetchParameters": {
+            "TestName": "build",
+            "Steps": [
+                {"name": "cmd", "label": "clone", "parameters": {}},
+                {"name": "cmd", "Label": "make", "parameters": {}},
+            ]
+        }
+    }],
+    "Reporting": {"RunReporters": [{"Name": "TargetSuccess"}]}
+}`
+	desc, err := ParseJobDescriptor("build.json", []byte(data))
+	if err != nil {
+		t.Fatalf("ParseJobDescriptor failed: %v", err)
+	}
+	if name, err := desc.Name(); err != nil || name != "Build Test" {
+		t.Errorf("got %q, %v want %q", name, err, "Build Test")
+	}
+	if want := []string{"coreboot", "build"}; !reflect.DeepEqual(desc.Tags, want) {
+		t.Errorf("got %v want %v", desc.Tags, want)
+	}
+	if want := []string{"clone", "make"}; !reflect.DeepEqual(desc.StepLabels(), want) {
+		t.Errorf("got %v want %v", desc.StepLabels(), want)
+	}
+	if len(desc.Reporting.RunReporters) != 1 || desc.Reporting.RunReporters[0].Name != "TargetSuccess" {
+		t.Errorf("got %+v want the TargetSuccess run reporter", desc.Reporting)
+	}
+
+	t.Run("missing JobName", func(t *testing.T) {
+		desc, err := ParseJobDescriptor("build.json", []byte(`{"Runs": 1}`))
+		if err != nil {
+			t.Fatalf("ParseJobDescriptor failed: %v", err)
+		}
+		if _, err := desc.Name(); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("JobName is no string", func(t *testing.T) {
+		if _, err := ParseJobDescriptor("build.json", []byte(`{"JobName": 42}`)); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+}
+
+// Test for JobDescriptor.JSON, if the fields that are not modelled are sent to the ConTest server unchanged,
+// also after the hooks changed the job
+func TestJobDescriptorJSON(t *testing.T) {
+	data := `{
+    "JobName": "Firmware Test",
+    "Runs": 18446744073709551615,
+    "Priority": {"Queue": "lab", "Weight": 3},
+    "TestDescriptors": [{
+        "TargetManagerName": "TargetList",
+        "TestFetcherName": "gitfetcher",
+        "TestFetcherFetchParameters": {"TestName": "boot", "Repository": "https://github.com/9elements/tests", "Ref": "main"}
+    }]
+}`
+	desc, err := ParseJobDescriptor("firmware.json", []byte(data))
+	if err != nil {
+		t.Fatalf("ParseJobDescriptor failed: %v", err)
+	}
+	got, err := desc.JSON()
+	if err != nil || string(got) != data {
+		t.Fatalf("got %s, %v want the rendered descriptor %s", got, err, data)
+	}
+
+	job := NewPreJobContext(Event{}, "firmware.json", "Firmware Test", desc, nil)
+	job.AddTags("nightly")
+	job.AppendSteps(TestStep{Name: "cmd", Label: "poweroff"})
+	applied, err := job.Apply(desc)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if applied, err = applied.WithJobName("Firmware Test [lab]"); err != nil {
+		t.Fatalf("WithJobName failed: %v", err)
+	}
+	if got, err = applied.JSON(); err != nil {
+		t.Fatalf("JSON failed: %v", err)
+	}
+	var body, want map[string]interface{}
+	if err := json.Unmarshal(got, &body); err != nil {
+		t.Fatalf("got invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(data), &want); err != nil {
+		t.Fatal(err)
+	}
+	want["JobName"] = "Firmware Test [lab]"
+	want["Tags"] = []interface{}{"nightly"}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("got %v want %v", body, want)
+	}
+	if !strings.Contains(string(got), "18446744073709551615") {
+		t.Errorf("got %s want the Runs unchanged", got)
+	}
+}
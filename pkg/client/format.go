@@ -0,0 +1,370 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DescriptorError is a syntax or schema error in a job descriptor file, with the lines of its problems
+type DescriptorError struct {
+	File     string
+	Problems []LineProblem
+	Err      error // Error of the parser
+}
+
+// LineProblem is a problem in a line of a job descriptor file
+type LineProblem struct {
+	Line int // 0 if the line is not known
+	Msg  string
+}
+
+func (e *DescriptorError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		if problem.Line > 0 {
+			lines = append(lines, fmt.Sprintf("%s:%d: %s", e.File, problem.Line, problem.Msg))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", e.File, problem.Msg))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *DescriptorError) Unwrap() error {
+	return e.Err
+}
+
+// DetectYAML returns true if the job descriptor file is written in YAML. The extension of the
+// file decides, otherwise the content is sniffed: descriptors starting with "{" are JSON.
+func DetectYAML(file string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json", ".json5", ".jsonc":
+		return false
+	}
+	// Skip the byte order mark, whitespace and comments before the first token
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n")
+		switch {
+		case bytes.HasPrefix(data, []byte("//")):
+			end := bytes.IndexByte(data, '\n')
+			if end < 0 {
+				return false
+			}
+			data = data[end:]
+		case bytes.HasPrefix(data, []byte("/*")):
+			end := bytes.Index(data, []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			data = data[end+2:]
+		default:
+			return !bytes.HasPrefix(data, []byte("{"))
+		}
+	}
+}
+
+// NormalizeJSON converts a JSON job descriptor with comments, trailing commas or other JSON5
+// syntax like unquoted keys, single quoted strings and hexadecimal numbers to plain JSON.
+// The newlines are kept, so that the line numbers of errors still match the file.
+func NormalizeJSON(file string, data []byte) ([]byte, error) {
+	normalized, offset, err := json5ToJSON(data)
+	if err != nil {
+		problem := LineProblem{Line: bytes.Count(data[:offset], []byte("\n")) + 1, Msg: err.Error()}
+		return nil, &DescriptorError{File: file, Problems: []LineProblem{problem}, Err: err}
+	}
+	var raw interface{}
+	if err := json.Unmarshal(normalized, &raw); err != nil {
+		return nil, jsonError(file, normalized, err)
+	}
+	return normalized, nil
+}
+
+// json5ToJSON converts the JSON5 syntax outside of the strings to JSON and the single quoted strings to
+// double quoted ones. Comments and trailing commas are replaced with spaces. Syntax that JSON5 does
+// not allow either is copied, the JSON parser reports it. Errors are returned with their offset.
+func json5ToJSON(data []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(data))
+	lastComma := -1
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			out = append(out, c)
+			i++
+			continue
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			end := skipJSONComment(data, i)
+			if end < 0 {
+				return nil, i, fmt.Errorf("unterminated comment")
+			}
+			out = appendBlank(out, data[i:end])
+			i = end
+			continue
+		case c == ',':
+			out = append(out, c)
+			lastComma = len(out) - 1
+			i++
+			continue
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			out = append(out, c)
+		case c == '"' || c == '\'':
+			var err error
+			out, i, err = appendJSONString(out, data, i)
+			if err != nil {
+				return nil, i, err
+			}
+			lastComma = -1
+			continue
+		case c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9':
+			var err error
+			out, i, err = appendJSONNumber(out, data, i)
+			if err != nil {
+				return nil, i, err
+			}
+			lastComma = -1
+			continue
+		case isIdentifierByte(c):
+			end := i
+			for end < len(data) && (isIdentifierByte(data[end]) || data[end] >= '0' && data[end] <= '9') {
+				end++
+			}
+			ident := string(data[i:end])
+			switch {
+			case ident == "Infinity" || ident == "NaN":
+				return nil, i, fmt.Errorf("%s can not be represented in JSON", ident)
+			case ident != "true" && ident != "false" && ident != "null" && nextJSONByte(data, end) == ':':
+				// Unquoted keys are quoted, other identifiers are left to the JSON parser
+				out = append(out, '"')
+				out = append(out, ident...)
+				out = append(out, '"')
+			default:
+				out = append(out, ident...)
+			}
+			i = end
+			lastComma = -1
+			continue
+		default:
+			out = append(out, c)
+		}
+		lastComma = -1
+		i++
+	}
+	return out, 0, nil
+}
+
+// appendJSONString appends the double or single quoted string at data[start] as a JSON string and
+// returns the offset after it. Line continuations are removed from the string, their newlines are
+// appended after it.
+func appendJSONString(out []byte, data []byte, start int) ([]byte, int, error) {
+	quote := data[start]
+	newlines := 0
+	out = append(out, '"')
+	for i := start + 1; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == quote:
+			out = append(out, '"')
+			for ; newlines > 0; newlines-- {
+				out = append(out, '\n')
+			}
+			return out, i + 1, nil
+		case c == '\n' || c == '\r':
+			return nil, start, fmt.Errorf("unterminated string")
+		case c == '"':
+			out = append(out, '\\', '"')
+		case c != '\\':
+			out = append(out, c)
+		case i+1 >= len(data):
+			return nil, start, fmt.Errorf("unterminated string")
+		default:
+			i++
+			switch e := data[i]; {
+			case e == '\n':
+				newlines++
+			case e == '\r':
+				newlines++
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case strings.IndexByte(`"\\/bfnrtu`, e) >= 0:
+				out = append(out, '\\', e)
+			case e == 'v':
+				out = append(out, `\u000b`...)
+			case e == '0' && (i+1 >= len(data) || data[i+1] < '0' || data[i+1] > '9'):
+				out = append(out, `\u0000`...)
+			case e == 'x':
+				if i+2 >= len(data) || !isHexByte(data[i+1]) || !isHexByte(data[i+2]) {
+					return nil, i - 1, fmt.Errorf("invalid hexadecimal escape in string")
+				}
+				out = append(out, `\u00`...)
+				out = append(out, data[i+1:i+3]...)
+				i += 2
+			case e >= '1' && e <= '9':
+				return nil, i - 1, fmt.Errorf("invalid escape \\%c in string", e)
+			default:
+				// Other characters, e.g. the quote of single quoted strings, stand for themselves
+				out = append(out, e)
+			}
+		}
+	}
+	return nil, start, fmt.Errorf("unterminated string")
+}
+
+// appendJSONNumber appends the JSON5 number at data[start] as a JSON number and returns the offset after it.
+// Hexadecimal numbers are converted, a leading plus is removed and a leading or trailing decimal point gets a zero.
+func appendJSONNumber(out []byte, data []byte, start int) ([]byte, int, error) {
+	i := start
+	switch data[i] {
+	case '-':
+		out = append(out, '-')
+		i++
+	case '+':
+		i++
+	}
+	if i+1 < len(data) && data[i] == '0' && (data[i+1] == 'x' || data[i+1] == 'X') {
+		end := i + 2
+		for end < len(data) && isHexByte(data[end]) {
+			end++
+		}
+		value, err := strconv.ParseUint(string(data[i+2:end]), 16, 64)
+		if err != nil {
+			return nil, start, fmt.Errorf("invalid hexadecimal number %s", data[start:end])
+		}
+		return strconv.AppendUint(out, value, 10), end, nil
+	}
+	for _, ident := range []string{"Infinity", "NaN"} {
+		if bytes.HasPrefix(data[i:], []byte(ident)) {
+			return nil, start, fmt.Errorf("%s can not be represented in JSON", ident)
+		}
+	}
+	if i < len(data) && data[i] == '.' {
+		out = append(out, '0')
+	}
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.':
+			if i+1 >= len(data) || data[i+1] < '0' || data[i+1] > '9' {
+				out = append(out, '.', '0')
+				continue
+			}
+		case (c == '+' || c == '-') && (data[i-1] == 'e' || data[i-1] == 'E'):
+		case c == 'e' || c == 'E':
+		default:
+			return out, i, nil
+		}
+		out = append(out, c)
+	}
+	return out, i, nil
+}
+
+// skipJSONComment returns the offset after the comment at data[start], -1 if a block comment does not end
+func skipJSONComment(data []byte, start int) int {
+	if data[start+1] == '/' {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			return len(data)
+		}
+		return start + end
+	}
+	end := bytes.Index(data[start+2:], []byte("*/"))
+	if end < 0 {
+		return -1
+	}
+	return start + 2 + end + 2
+}
+
+// nextJSONByte returns the next byte after the whitespace and comments starting at data[start], 0 at the end
+func nextJSONByte(data []byte, start int) byte {
+	for i := start; i < len(data); {
+		switch c := data[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			i = skipJSONComment(data, i)
+			if i < 0 {
+				return 0
+			}
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+// appendBlank appends the text with every character except the newlines replaced by a space
+func appendBlank(out []byte, text []byte) []byte {
+	for _, c := range text {
+		if c != '\n' {
+			c = ' '
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// isIdentifierByte returns true if the byte can start an unquoted JSON5 key, non-ASCII letters are accepted as well
+func isIdentifierByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
+
+// isHexByte returns true if the byte is a hexadecimal digit
+func isHexByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// yamlLine matches the line number in the errors of the YAML parser
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// yamlError converts the error of the YAML parser to a DescriptorError
+func yamlError(file string, err error) error {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+	descErr := &DescriptorError{File: file, Err: err}
+	for _, msg := range msgs {
+		problem := LineProblem{Msg: strings.TrimPrefix(msg, "yaml: ")}
+		if match := yamlLine.FindStringSubmatch(msg); match != nil {
+			problem.Line, _ = strconv.Atoi(match[1])
+			problem.Msg = msg[len(match[0]):]
+		}
+		descErr.Problems = append(descErr.Problems, problem)
+	}
+	return descErr
+}
+
+// jsonError converts the error of the JSON parser to a DescriptorError, the line is
+// calculated from the offset of the error
+func jsonError(file string, data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	problem := LineProblem{Msg: strings.TrimPrefix(err.Error(), "json: ")}
+	if offset > 0 && offset <= int64(len(data)) {
+		problem.Line = bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	return &DescriptorError{File: file, Problems: []LineProblem{problem}, Err: err}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDetectYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want bool
+	}{
+		{"yaml extension", "job.yml", `{"JobName": "x"}`, true},
+		{"json extension", "job.json", "JobName: x", false},
+		{"json5 extension", "job.json5", "JobName: x", false},
+		{"sniffed json", "job", "\n  {\"JobName\": \"x\"}", false},
+		{"sniffed json after comments", "<stdin>", "// build test\n/* coreboot */ {\"JobName\": \"x\"}", false},
+		{"sniffed yaml", "job", "# build test\nJobName: x", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DetectYAML(test.file, []byte(test.data)); got != test.want {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	data := `{
+    // The name of the job
+    "JobName": "build // test", /* inline */
+    "Tags": ["a", "b",],
+}`
+	got, err := NormalizeJSON("job.json", []byte(data))
+	if err != nil {
+		t.Fatalf("NormalizeJSON failed: %v", err)
+	}
+	var body struct {
+		JobName string
+		Tags    []string
+	}
+	if err := json.Unmarshal(got, &body); err != nil {
+		t.Fatalf("got invalid JSON %s: %v", got, err)
+	}
+	if body.JobName != "build // test" || len(body.Tags) != 2 {
+		t.Errorf("got %+v want JobName \"build // test\" and 2 tags", body)
+	}
+}
+
+func TestNormalizeJSON5(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unquoted keys", `{JobName: 'build', $runs: 2}`, `{"JobName": "build", "$runs": 2}`},
+		{"single quoted string", `{'JobName': 'it\'s "quoted"'}`, `{"JobName": "it's \"quoted\""}`},
+		{"escapes", `{"a": '\x41\v\0'}`, `{"a": "\u0041\u000b\u0000"}`},
+		{"numbers", `[0x1F, +1, .5, 5., -0XFF, 1.e3]`, `[31, 1, 0.5, 5.0, -255, 1.0e3]`},
+		{"line continuation", "{\"a\": 'one \\\ntwo'}", "{\"a\": \"one two\"\n}"},
+		{"keywords", `{a: true, b: null, c: false,}`, `{"a": true, "b": null, "c": false }`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NormalizeJSON("job.json5", []byte(test.data))
+			if err != nil || string(got) != test.want {
+				t.Errorf("got %s, %v want %s", got, err, test.want)
+			}
+		})
+	}
+
+	errTests := []struct {
+		name string
+		data string
+		want string
+	}{
+		// YAML 1.1 keywords are no JSON5 values, they must not become booleans
+		{"yaml keyword", "{\n  \"Enabled\": on\n}", "job.json5:2: invalid character 'o' looking for beginning of value"},
+		{"infinity", "{\n  \"Runs\": -Infinity\n}", "job.json5:2: Infinity can not be represented in JSON"},
+		{"line after continuation", "{\"a\": 'one \\\ntwo',\n  \"b\" 2}", "job.json5:3: invalid character '2' after object key"},
+		{"unterminated string", "{\n  \"a\": 'one\n}", "job.json5:2: unterminated string"},
+	}
+	for _, test := range errTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NormalizeJSON("job.json5", []byte(test.data))
+			var descErr *DescriptorError
+			if !errors.As(err, &descErr) || err.Error() != test.want {
+				t.Errorf("got %v want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJobDescriptorLine(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"type error", "{\n  \"JobName\": \"x\",\n  \"Runs\": \"two\"\n}", "job.json:3: cannot unmarshal string into Go struct field JobDescriptor.Runs of type uint"},
+		{"syntax error", "{\n  \"JobName\": \"x\"\n  \"Runs\": 2\n}", "job.json:3: invalid character '\"' after object key:value pair"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeJobDescriptor("job.json", []byte(test.data), false)
+			var descErr *DescriptorError
+			if !errors.As(err, &descErr) {
+				t.Fatalf("got %v want a DescriptorError", err)
+			}
+			if err.Error() != test.want {
+				t.Errorf("got %q want %q", err.Error(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/facebookincubator/contest/pkg/api"
+	"github.com/facebookincubator/contest/pkg/job"
+	"github.com/facebookincubator/contest/pkg/transport"
+	"github.com/facebookincubator/contest/pkg/types"
+)
+
+// JobTracker keeps track of the jobs that were started by the client and reports if they finished
+type JobTracker interface {
+	// AddJob registers a job right after it was started
+	AddJob(ctx context.Context, jobID int) error
+	// JobFinished returns true if the job reached a final state
+	JobFinished(ctx context.Context, jobID int) (bool, error)
+}
+
+// NewJobTracker returns the JobTracker that is selected in the client descriptor.
+// By default the status of the jobs is polled from the ConTest server, the external
+// job status API is only used if FlagStatusAPI is set.
+func NewJobTracker(cd ClientDescriptor, transport transport.Transport) JobTracker {
+	if cd.Flags.FlagStatusAPI != nil && *cd.Flags.FlagStatusAPI {
+		return &APIJobTracker{Addr: *cd.Flags.FlagAddr + *cd.Flags.FlagPortAPI}
+	}
+	return &ServerJobTracker{Transport: transport, Requestor: *cd.Flags.FlagRequestor}
+}
+
+// ServerJobTracker tracks the jobs by polling their status from the ConTest server
+type ServerJobTracker struct {
+	Transport transport.Transport
+	Requestor string
+}
+
+// AddJob does nothing, the ConTest server already knows about the job
+func (t *ServerJobTracker) AddJob(ctx context.Context, jobID int) error {
+	return nil
+}
+
+// JobFinished requests the status of the job and checks if it is in a final state
+func (t *ServerJobTracker) JobFinished(ctx context.Context, jobID int) (bool, error) {
+	statusResp, err := JobStatus(ctx, t.Transport, t.Requestor, jobID)
+	if err != nil {
+		return false, err
+	}
+	return JobCompleted(statusResp.Data.Status), nil
+}
+
+// APIJobTracker tracks the jobs with an external job status API. The API has to be
+// notified by the job itself, e.g. with the PostDone reporter.
+type APIJobTracker struct {
+	Addr string // [scheme://]host:port of the job status API
+}
+
+// AddJob adds the job to the API DB with the status 'not finished'
+func (t *APIJobTracker) AddJob(ctx context.Context, jobID int) error {
+	// Create Json Body for API Request to set a status for the started Job
+	data := map[string]interface{}{
+		"ID":     jobID,
+		"Status": false,
+	}
+
+	// Marshal that data
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("could not parse data to json format: %w", err)
+	}
+
+	// Add the job to the Api DB
+	addr := strings.Join([]string{t.Addr, "/addjobstatus/"}, "")
+	resp, err := http.Post(addr, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("could not post data to API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the HTTP Post responded a statuscode != 200: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// JobFinished sends a request to the API that returns if the job finished or not
+func (t *APIJobTracker) JobFinished(ctx context.Context, jobID int) (bool, error) {
+	// API request
+	readJobStatus := strings.Join([]string{t.Addr, "/readjobstatus/", fmt.Sprint(jobID)}, "")
+	resp, err := http.Get(readJobStatus)
+	if err != nil {
+		return false, fmt.Errorf("could not request data from API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// If API request was not 200 (StatusOK)
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("the status code of the respone is != 200 (StatusOk)")
+	}
+
+	// Unmarshal the status of the job that was requested
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("error reading the HTTP response: %w", err)
+	}
+	var finished bool
+	if err := json.Unmarshal(bodyBytes, &finished); err != nil {
+		return false, fmt.Errorf("error decoding the HTTP response: %w", err)
+	}
+	// Return status
+	return finished, nil
+}
+
+// JobStatus retrieves the status of a job from the ConTest server
+func JobStatus(ctx context.Context, transport transport.Transport, requestor string, jobID int) (*api.StatusResponse, error) {
+	statusResp, err := transport.Status(ctx, requestor, types.JobID(jobID))
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the status of the Job %d: %w", jobID, err)
+	}
+	if statusResp.Err != nil {
+		return nil, fmt.Errorf("the server returned an error for the Job %d: %v", jobID, statusResp.Err)
+	}
+	if statusResp.Data.Status == nil {
+		return nil, fmt.Errorf("the server returned no status for the Job %d", jobID)
+	}
+	return statusResp, nil
+}
+
+// WaitForJob polls the tracker until the job finished and returns the final status of the job
+func WaitForJob(ctx context.Context, tracker JobTracker, transport transport.Transport, requestor string,
+	jobID int, jobWaitPoll time.Duration) (*api.StatusResponse, error) {
+	for {
+		finished, err := tracker.JobFinished(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if finished {
+			return JobStatus(ctx, transport, requestor, jobID)
+		}
+		// Sleep for the poll interval and than continue
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jobWaitPoll):
+		}
+	}
+}
+
+// JobCompleted returns true if the job reached one of the final states
+func JobCompleted(status *job.Status) bool {
+	if status == nil {
+		return false
+	}
+	for _, eventName := range job.JobCompletionEvents {
+		if status.State == string(eventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// JobSucceeded returns true if the job completed and all of its run and final reports were successful
+func JobSucceeded(status *job.Status) bool {
+	if status == nil || status.State != string(job.EventJobCompleted) || status.JobReport == nil {
+		return false
+	}
+	for _, runReports := range status.JobReport.RunReports {
+		for _, report := range runReports {
+			if !report.Success {
+				return false
+			}
+		}
+	}
+	for _, report := range status.JobReport.FinalReports {
+		if !report.Success {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/facebookincubator/contest/pkg/job"
+)
+
+// Test for JobCompleted and JobSucceeded, if the job state and reports are evaluated correctly
+func TestJobSucceeded(t *testing.T) {
+	success := &job.Report{Success: true}
+	failure := &job.Report{Success: false}
+
+	tests := []struct {
+		name      string
+		status    *job.Status
+		completed bool
+		succeeded bool
+	}{
+		{"nil status", nil, false, false},
+		{"running", &job.Status{State: string(job.EventJobStarted)}, false, false},
+		{"completed", &job.Status{State: string(job.EventJobCompleted), JobReport: &job.JobReport{
+			RunReports:   [][]*job.Report{{success, success}},
+			FinalReports: []*job.Report{success},
+		}}, true, true},
+		{"failed run report", &job.Status{State: string(job.EventJobCompleted), JobReport: &job.JobReport{
+			RunReports: [][]*job.Report{{success}, {failure}},
+		}}, true, false},
+		{"failed final report", &job.Status{State: string(job.EventJobCompleted), JobReport: &job.JobReport{
+			RunReports:   [][]*job.Report{{success}},
+			FinalReports: []*job.Report{failure},
+		}}, true, false},
+		{"cancelled", &job.Status{State: string(job.EventJobCancelled)}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := JobCompleted(test.status); got != test.completed {
+				t.Errorf("JobCompleted: got %t want %t", got, test.completed)
+			}
+			if got := JobSucceeded(test.status); got != test.succeeded {
+				t.Errorf("JobSucceeded: got %t want %t", got, test.succeeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MatrixCombination is one combination of the matrix values of a job template
+type MatrixCombination struct {
+	Values map[string]interface{} // Value per matrix axis, exposed to the job template as .Matrix
+	Suffix string                 // Suffix of the JobName, e.g. " (HostPrefix=tp-slot, Payload=linuxboot)"
+}
+
+// MatrixCombinations expands the matrix of the template config into all combinations of its values.
+// The axes are ordered by name. Without a matrix a single combination without values is returned.
+func (c TemplateConfig) MatrixCombinations() []MatrixCombination {
+	axes := make([]string, 0, len(c.Matrix))
+	for axis, values := range c.Matrix {
+		if len(values) > 0 {
+			axes = append(axes, axis)
+		}
+	}
+	sort.Strings(axes)
+
+	combinations := []map[string]interface{}{{}}
+	for _, axis := range axes {
+		var expanded []map[string]interface{}
+		for _, combination := range combinations {
+			for _, value := range c.Matrix[axis] {
+				values := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					values[k] = v
+				}
+				values[axis] = value
+				expanded = append(expanded, values)
+			}
+		}
+		combinations = expanded
+	}
+
+	result := make([]MatrixCombination, 0, len(combinations))
+	for _, values := range combinations {
+		var suffix string
+		if len(axes) > 0 {
+			parts := make([]string, 0, len(axes))
+			for _, axis := range axes {
+				parts = append(parts, fmt.Sprintf("%s=%v", axis, values[axis]))
+			}
+			suffix = " (" + strings.Join(parts, ", ") + ")"
+		}
+		result = append(result, MatrixCombination{Values: values, Suffix: suffix})
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test for MatrixCombinations, if every combination of the matrix values gets its own JobName suffix
+func TestMatrixCombinations(t *testing.T) {
+	config := TemplateConfig{Matrix: map[string][]interface{}{
+		"Payload":    {"linuxboot", "tianocore"},
+		"HostPrefix": {"yv3-evt-slot", "tp-slot"},
+		"Empty":      {},
+	}}
+	var got []string
+	for _, combination := range config.MatrixCombinations() {
+		got = append(got, combination.Suffix)
+		if len(combination.Values) != 2 {
+			t.Errorf("got values %v want 2 values", combination.Values)
+		}
+	}
+	want := []string{
+		" (HostPrefix=yv3-evt-slot, Payload=linuxboot)",
+		" (HostPrefix=yv3-evt-slot, Payload=tianocore)",
+		" (HostPrefix=tp-slot, Payload=linuxboot)",
+		" (HostPrefix=tp-slot, Payload=tianocore)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	// Without a matrix a single job is started
+	combinations := TemplateConfig{}.MatrixCombinations()
+	if len(combinations) != 1 || combinations[0].Suffix != "" {
+		t.Errorf("got %v want a single combination without suffix", combinations)
+	}
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TemplateConfig describes how the job of a template is embedded into the pipeline of a webhook
+type TemplateConfig struct {
+	DependsOn []string                 // Job templates whose jobs have to succeed before the job of this template is started
+	Outputs   map[string]string        // Values passed to the downstream templates, rendered with [[ ]] after the job succeeded
+	Matrix    map[string][]interface{} // Values per axis, a job is started for every combination of the values
+}
+
+// PipelineStages orders the job templates into stages, every template only depends on templates of
+// earlier stages. Dependencies that were not selected are added, since their outputs are needed.
+func (cd ClientDescriptor) PipelineStages(templates []string) ([][]string, error) {
+	// Add the dependencies of the templates
+	var all []string
+	selected := make(map[string]bool)
+	var add func(template string)
+	add = func(template string) {
+		if selected[template] {
+			return
+		}
+		selected[template] = true
+		all = append(all, template)
+		for _, dependency := range cd.Templates[template].DependsOn {
+			add(dependency)
+		}
+	}
+	for _, template := range templates {
+		add(template)
+	}
+
+	// The outputs of the jobs of the matrix combinations could not be told apart
+	for _, template := range all {
+		if config := cd.Templates[template]; len(config.Outputs) > 0 && len(config.MatrixCombinations()) > 1 {
+			return nil, fmt.Errorf("the job template %s has a matrix and outputs, only templates without a matrix can have outputs", template)
+		}
+	}
+
+	// The stage of a template is one after the last stage of its dependencies
+	stage := make(map[string]int)
+	visiting := make(map[string]bool)
+	var stageOf func(template string, path []string) (int, error)
+	stageOf = func(template string, path []string) (int, error) {
+		if s, found := stage[template]; found {
+			return s, nil
+		}
+		path = append(path, template)
+		if visiting[template] {
+			return 0, fmt.Errorf("the job templates depend on each other: %s", strings.Join(path, " -> "))
+		}
+		visiting[template] = true
+		s := 0
+		for _, dependency := range cd.Templates[template].DependsOn {
+			dependencyStage, err := stageOf(dependency, path)
+			if err != nil {
+				return 0, err
+			}
+			if dependencyStage+1 > s {
+				s = dependencyStage + 1
+			}
+		}
+		visiting[template] = false
+		stage[template] = s
+		return s, nil
+	}
+
+	var stages [][]string
+	for _, template := range all {
+		s, err := stageOf(template, nil)
+		if err != nil {
+			return nil, err
+		}
+		for len(stages) <= s {
+			stages = append(stages, nil)
+		}
+		stages[s] = append(stages[s], template)
+	}
+	return stages, nil
+}
+
+// Upstream returns true if another of the templates depends on the template
+func (cd ClientDescriptor) Upstream(template string, templates []string) bool {
+	for _, t := range templates {
+		for _, dependency := range cd.Templates[t].DependsOn {
+			if dependency == template {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The events of the test steps are decoded from the JSON of the job status
+type eventStatuses struct {
+	RunStatus   *eventRunStatus
+	RunStatuses []eventRunStatus
+}
+
+type eventRunStatus struct {
+	TestStatuses []struct {
+		TestStepStatuses []struct {
+			Events         []testEvent
+			TargetStatuses []struct {
+				Events []testEvent
+			}
+		}
+	}
+}
+
+type testEvent struct {
+	Data struct {
+		EventName string
+		Payload   json.RawMessage
+	}
+}
+
+// JobEvents returns the payloads of the test step events in the JSON job status per event name,
+// e.g. the URL of a binary that was uploaded by the s3fileupload step. Payloads with a "Msg"
+// field are reduced to the message, other payloads are returned as JSON.
+func JobEvents(statusJSON []byte) (map[string][]string, error) {
+	var status eventStatuses
+	if err := json.Unmarshal(statusJSON, &status); err != nil {
+		return nil, fmt.Errorf("could not decode the events of the job: %w", err)
+	}
+	runs := status.RunStatuses
+	if len(runs) == 0 && status.RunStatus != nil {
+		runs = []eventRunStatus{*status.RunStatus}
+	}
+
+	events := make(map[string][]string)
+	addEvents := func(testEvents []testEvent) {
+		for _, ev := range testEvents {
+			if ev.Data.EventName == "" {
+				continue
+			}
+			events[ev.Data.EventName] = append(events[ev.Data.EventName], eventPayload(ev.Data.Payload))
+		}
+	}
+	for _, run := range runs {
+		for _, test := range run.TestStatuses {
+			for _, step := range test.TestStepStatuses {
+				addEvents(step.Events)
+				for _, target := range step.TargetStatuses {
+					addEvents(target.Events)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// eventPayload returns the message of the event payload
+func eventPayload(payload json.RawMessage) string {
+	var msg struct {
+		Msg string
+	}
+	if err := json.Unmarshal(payload, &msg); err == nil && msg.Msg != "" {
+		return msg.Msg
+	}
+	var s string
+	if err := json.Unmarshal(payload, &s); err == nil {
+		return s
+	}
+	return string(payload)
+}
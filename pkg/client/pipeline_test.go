@@ -0,0 +1,67 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test for PipelineStages, if the templates are ordered by their dependencies
+func TestPipelineStages(t *testing.T) {
+	cd := ClientDescriptor{Templates: map[string]TemplateConfig{
+		"qemu.yaml":       {DependsOn: []string{"build.yaml"}},
+		"archercity.yaml": {DependsOn: []string{"build.yaml", "qemu.yaml"}},
+	}}
+
+	// Dependencies that were not selected are added
+	got, err := cd.PipelineStages([]string{"archercity.yaml", "lint.yaml"})
+	if err != nil {
+		t.Fatalf("PipelineStages failed: %v", err)
+	}
+	want := [][]string{{"build.yaml", "lint.yaml"}, {"qemu.yaml"}, {"archercity.yaml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !cd.Upstream("build.yaml", []string{"qemu.yaml"}) || cd.Upstream("lint.yaml", []string{"qemu.yaml"}) {
+		t.Errorf("Upstream: build.yaml has to be upstream of qemu.yaml, lint.yaml not")
+	}
+
+	// Cyclic dependencies are an error
+	cd.Templates["build.yaml"] = TemplateConfig{DependsOn: []string{"archercity.yaml"}}
+	if _, err := cd.PipelineStages([]string{"qemu.yaml"}); err == nil {
+		t.Errorf("got no error want an error for the cyclic dependencies")
+	}
+
+	// The outputs of the jobs of a matrix can not be told apart
+	cd.Templates["build.yaml"] = TemplateConfig{Outputs: map[string]string{"BinaryURL": "[[ .Events.URL | first ]]"},
+		Matrix: map[string][]interface{}{"Payload": {"linuxboot", "tianocore"}}}
+	if _, err := cd.PipelineStages([]string{"qemu.yaml"}); err == nil {
+		t.Errorf("got no error want an error for the outputs of the matrix")
+	}
+}
+
+// Test for JobEvents, if the event payloads of the test steps and targets are collected
+func TestJobEvents(t *testing.T) {
+	statusJSON := []byte(`{
+    "RunStatuses": [{
+        "TestStatuses": [{
+            "TestStepStatuses": [{
+                "Events": [{"Data": {"EventName": "CmdStart", "Payload": "make"}}],
+                "TargetStatuses": [{
+                    "Events": [{"Data": {"EventName": "URL", "Payload": {"Msg": "https://example.com/coreboot.rom.gz"}}}]
+                }]
+            }]
+        }]
+    }]
+}`)
+	got, err := JobEvents(statusJSON)
+	if err != nil {
+		t.Fatalf("JobEvents failed: %v", err)
+	}
+	want := map[string][]string{
+		"CmdStart": {"make"},
+		"URL":      {"https://example.com/coreboot.rom.gz"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"strings"
+)
+
+// JobPreHook is implemented by the PreJobExecutionHooks that want to see or change the jobs of a webhook.
+// PreJob is called for every job after its descriptor was rendered and before it is sent to the ConTest
+// server, in the order of the PreJobExecutionHooks in the config. Run is still called once per webhook.
+type JobPreHook interface {
+	PreJob(ctx context.Context, parameters interface{}, clientDescriptor ClientDescriptor, job *PreJobContext) error
+}
+
+// TestStep is a step of a literal test that a hook injects into the job descriptor
+type TestStep struct {
+	Name       string
+	Label      string
+	Parameters map[string][]interface{}
+}
+
+// step converts the injected step to a step of the job descriptor
+func (s TestStep) step() Step {
+	parameters := make(map[string]interface{}, len(s.Parameters))
+	for name, values := range s.Parameters {
+		parameters[name] = values
+	}
+	return Step{Name: s.Name, Label: s.Label, Parameters: parameters}
+}
+
+// PreJobContext is the job that is about to be started. The hooks read the webhook event and the
+// rendered descriptor and change the job with the methods, the changes are applied after all hooks ran.
+type PreJobContext struct {
+	Event      Event          // Webhook that triggered the job
+	Template   string         // File name of the job template
+	JobName    string         // JobName of the descriptor including the suffix of the matrix combination
+	Descriptor *JobDescriptor // Rendered job descriptor, it must not be changed directly
+
+	vars         map[string]interface{}
+	varsChanged  bool
+	tags         []string
+	prependSteps []TestStep
+	appendSteps  []TestStep
+	veto         string
+}
+
+// NewPreJobContext creates the context of a job whose template was rendered with the variables
+func NewPreJobContext(event Event, template string, jobName string, descriptor *JobDescriptor, vars map[string]interface{}) *PreJobContext {
+	return &PreJobContext{Event: event, Template: template, JobName: jobName, Descriptor: descriptor, vars: vars}
+}
+
+// Var returns the template variable the descriptor was rendered with
+func (c *PreJobContext) Var(name string) (interface{}, bool) {
+	value, found := c.vars[name]
+	return value, found
+}
+
+// SetVar adds or overrides a template variable, the job template is rendered again with it
+func (c *PreJobContext) SetVar(name string, value interface{}) {
+	if c.vars == nil {
+		c.vars = make(map[string]interface{})
+	}
+	c.vars[name] = value
+	c.varsChanged = true
+}
+
+// Vars returns the template variables and true if a hook changed them
+func (c *PreJobContext) Vars() (map[string]interface{}, bool) {
+	return c.vars, c.varsChanged
+}
+
+// AddTags adds tags to the job, tags the descriptor already has are not added twice
+func (c *PreJobContext) AddTags(tags ...string) {
+	c.tags = append(c.tags, tags...)
+}
+
+// PrependSteps injects the steps before the steps of every literal test of the job
+func (c *PreJobContext) PrependSteps(steps ...TestStep) {
+	c.prependSteps = append(c.prependSteps, steps...)
+}
+
+// AppendSteps injects the steps after the steps of every literal test of the job
+func (c *PreJobContext) AppendSteps(steps ...TestStep) {
+	c.appendSteps = append(c.appendSteps, steps...)
+}
+
+// Veto prevents the job from being started, the reason is reported as commit status
+func (c *PreJobContext) Veto(reason string) {
+	if reason == "" {
+		reason = "vetoed by a PreJobExecutionHook"
+	}
+	c.veto = reason
+}
+
+// Vetoed returns the reason and true if a hook vetoed the job
+func (c *PreJobContext) Vetoed() (string, bool) {
+	return c.veto, c.veto != ""
+}
+
+// Changed returns true if the hooks added tags or steps
+func (c *PreJobContext) Changed() bool {
+	return len(c.tags) > 0 || len(c.prependSteps) > 0 || len(c.appendSteps) > 0
+}
+
+// Apply returns a copy of the rendered job descriptor with the tags and steps of the hooks.
+// The other fields of the rendered descriptor are kept.
+func (c *PreJobContext) Apply(desc *JobDescriptor) (*JobDescriptor, error) {
+	applied := *desc
+	applied.Tags = append([]string(nil), desc.Tags...)
+	for _, tag := range c.tags {
+		if !containsValue(applied.Tags, tag) {
+			applied.Tags = append(applied.Tags, tag)
+		}
+	}
+
+	applied.TestDescriptors = append([]TestDescriptor(nil), desc.TestDescriptors...)
+	for i, td := range applied.TestDescriptors {
+		if !strings.EqualFold(td.TestFetcherName, "literal") || td.TestFetcherFetchParameters == nil {
+			continue
+		}
+		params := *td.TestFetcherFetchParameters
+		params.Steps = make([]Step, 0, len(c.prependSteps)+len(td.TestFetcherFetchParameters.Steps)+len(c.appendSteps))
+		for _, step := range c.prependSteps {
+			params.Steps = append(params.Steps, step.step())
+		}
+		params.Steps = append(params.Steps, td.TestFetcherFetchParameters.Steps...)
+		for _, step := range c.appendSteps {
+			params.Steps = append(params.Steps, step.step())
+		}
+		applied.TestDescriptors[i].TestFetcherFetchParameters = &params
+	}
+
+	err := applied.editRaw(func(body map[string]interface{}) {
+		if len(c.tags) > 0 {
+			tags := make([]interface{}, 0, len(applied.Tags))
+			for _, tag := range applied.Tags {
+				tags = append(tags, tag)
+			}
+			body[rawKey(body, "Tags")] = tags
+		}
+		testDescriptors, _ := body[rawKey(body, "TestDescriptors")].([]interface{})
+		for _, item := range testDescriptors {
+			td, _ := item.(map[string]interface{})
+			if fetcher, _ := td[rawKey(td, "TestFetcherName")].(string); !strings.EqualFold(fetcher, "literal") {
+				continue
+			}
+			params, ok := td[rawKey(td, "TestFetcherFetchParameters")].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			stepsKey := rawKey(params, "Steps")
+			existing, _ := params[stepsKey].([]interface{})
+			steps := make([]interface{}, 0, len(c.prependSteps)+len(existing)+len(c.appendSteps))
+			for _, step := range c.prependSteps {
+				steps = append(steps, step.step())
+			}
+			steps = append(steps, existing...)
+			for _, step := range c.appendSteps {
+				steps = append(steps, step.step())
+			}
+			params[stepsKey] = steps
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &applied, nil
+}
+
+func containsValue(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
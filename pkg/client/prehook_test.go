@@ -0,0 +1,53 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreJobContextApply(t *testing.T) {
+	desc, err := ParseJobDescriptor("build.json", []byte(`{
+    "JobName": "Build Test",
+    "Tags": ["coreboot"],
+    "TestDescriptors": [
+        {"TestFetcherName": "literal", "TestFetcherFetchParameters": {"Steps": [{"name": "cmd", "label": "make", "parameters": {}}]}},
+        {"TestFetcherName": "URI", "TestFetcherFetchParameters": {"URI": "test.json"}}
+    ]
+}`))
+	if err != nil {
+		t.Fatalf("ParseJobDescriptor failed: %v", err)
+	}
+	job := NewPreJobContext(Event{}, "build.json", "Build Test", nil, nil)
+	job.AddTags("coreboot", "nightly")
+	job.PrependSteps(TestStep{Name: "cmd", Label: "flash", Parameters: map[string][]interface{}{"executable": {"flashrom"}}})
+	job.AppendSteps(TestStep{Name: "cmd", Label: "poweroff", Parameters: map[string][]interface{}{}})
+
+	got, err := job.Apply(desc)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if want := []string{"coreboot", "nightly"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("got %v want %v", got.Tags, want)
+	}
+	if want := []string{"flash", "make", "poweroff"}; !reflect.DeepEqual(got.StepLabels(), want) {
+		t.Errorf("got %v want %v", got.StepLabels(), want)
+	}
+	if steps := got.TestDescriptors[1].TestFetcherFetchParameters.Steps; steps != nil {
+		t.Errorf("got steps %v in the URI test descriptor want none", steps)
+	}
+	// The rendered descriptor is not changed
+	if want := []string{"make"}; !reflect.DeepEqual(desc.StepLabels(), want) {
+		t.Errorf("got %v want %v", desc.StepLabels(), want)
+	}
+
+	t.Run("veto", func(t *testing.T) {
+		job := NewPreJobContext(Event{}, "build.json", "Build Test", nil, nil)
+		if _, vetoed := job.Vetoed(); vetoed {
+			t.Fatalf("got vetoed want not vetoed")
+		}
+		job.Veto("the lab is in maintenance")
+		if reason, vetoed := job.Vetoed(); !vetoed || reason != "the lab is in maintenance" {
+			t.Errorf("got %q, %v want the reason of the veto", reason, vetoed)
+		}
+	})
+}
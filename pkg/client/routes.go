@@ -0,0 +1,142 @@
+package client
+
+import (
+	"path"
+	"strings"
+)
+
+// Route selects job templates for the webhooks that match all of its rules. Empty rules match
+// everything. The globs support "*" within a path segment and "**" for any number of segments.
+type Route struct {
+	Repositories []string // Globs of the "owner/name" of the repository, e.g. "9elements/*"
+	Branches     []string // Globs of the pushed branch or the base branch of the pull request
+	Events       []string // Event types, "push" or "pull_request"
+	Paths        []string // Globs of the changed files, at least one changed file has to match
+	Templates    []string // File names of the job templates that are started
+}
+
+// Event contains the properties of a webhook, the routes are matched against them and the
+// JobPreHooks get them
+type Event struct {
+	Repository   string   // "owner/name" of the repository
+	Branch       string   // Pushed branch or base branch of the pull request
+	Type         string   // Event type, "push" or "pull_request"
+	ChangedFiles []string // Changed files, nil if they are not known
+	DeliveryID   string   // ID of the webhook delivery
+	SHA          string   // Pushed commit or head commit of the pull request
+	BaseSHA      string   // Commit before the push or base commit of the pull request
+	Ref          string   // Full ref of the commit, e.g. refs/heads/main or refs/pull/1/head
+	PRNumber     int      // Number of the pull request or Gerrit change, 0 for pushes
+	PatchSet     int      // Number of the patchset of the Gerrit change, 0 for other forges
+	Title        string   // Title of the pull request or first line of the head commit message
+	Author       string   // Author of the pull request or sender of the push
+}
+
+// JobTemplates returns the job templates that are started for the event. Without routes all
+// templates of FlagJobTemplate are started. Templates of several matching routes are only started once.
+func (cd ClientDescriptor) JobTemplates(event Event) []string {
+	if len(cd.Routes) == 0 {
+		var templates []string
+		for _, template := range cd.Flags.FlagJobTemplate {
+			templates = append(templates, *template)
+		}
+		return templates
+	}
+
+	seen := make(map[string]bool)
+	var templates []string
+	for _, route := range cd.Routes {
+		if !route.Match(event) {
+			continue
+		}
+		for _, template := range route.Templates {
+			if !seen[template] {
+				seen[template] = true
+				templates = append(templates, template)
+			}
+		}
+	}
+	return templates
+}
+
+// HasTemplate returns true if the job template is started by a route or by FlagJobTemplate, or if it is configured
+// in Templates. Only these templates can be started with the /run command.
+func (cd ClientDescriptor) HasTemplate(template string) bool {
+	if _, found := cd.Templates[template]; found {
+		return true
+	}
+	for _, route := range cd.Routes {
+		for _, routeTemplate := range route.Templates {
+			if routeTemplate == template {
+				return true
+			}
+		}
+	}
+	for _, flagTemplate := range cd.Flags.FlagJobTemplate {
+		if flagTemplate != nil && *flagTemplate == template {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if the event matches all rules of the route. If the changed files
+// are not known, the path rules match, so that no tests are skipped by mistake.
+func (route Route) Match(event Event) bool {
+	if len(route.Repositories) > 0 && !matchAny(route.Repositories, event.Repository) {
+		return false
+	}
+	if len(route.Branches) > 0 && !matchAny(route.Branches, event.Branch) {
+		return false
+	}
+	if len(route.Events) > 0 && !known(route.Events, event.Type) {
+		return false
+	}
+	if len(route.Paths) > 0 && event.ChangedFiles != nil {
+		for _, file := range event.ChangedFiles {
+			if matchAny(route.Paths, file) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchAny returns true if the name matches one of the globs
+func matchAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if MatchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches the slash separated name against the glob. "**" matches any number of
+// path segments, the other segments are matched with path.Match.
+func MatchGlob(glob string, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob []string, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// Try to match the rest of the glob at every following segment
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, err := path.Match(glob[0], name[0]); err != nil || !matched {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test for JobTemplates, if the templates are selected by repository, branch, event and changed files
+func TestJobTemplates(t *testing.T) {
+	cd := ClientDescriptor{Routes: []Route{
+		{
+			Repositories: []string{"9elements/*"},
+			Paths:        []string{"src/**", "Makefile*"},
+			Templates:    []string{"build.yaml", "qemu.yaml"},
+		},
+		{
+			Repositories: []string{"9elements/coreboot-spr-sp"},
+			Branches:     []string{"master", "release/**"},
+			Events:       []string{"pull_request"},
+			Paths:        []string{"src/mainboard/intel/archercity/**"},
+			Templates:    []string{"build.yaml", "archercity.yaml"},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{"docs only", Event{Repository: "9elements/coreboot-spr-sp", Branch: "master", Type: "push", ChangedFiles: []string{"Documentation/index.md"}}, nil},
+		{"source change", Event{Repository: "9elements/coreboot-spr-sp", Branch: "master", Type: "push", ChangedFiles: []string{"Documentation/index.md", "src/lib/main.c"}}, []string{"build.yaml", "qemu.yaml"}},
+		{"top level file", Event{Repository: "9elements/coreboot-spr-sp", Branch: "feature/x", Type: "push", ChangedFiles: []string{"Makefile.inc"}}, []string{"build.yaml", "qemu.yaml"}},
+		{"mainboard pull request", Event{Repository: "9elements/coreboot-spr-sp", Branch: "release/4.20/rc1", Type: "pull_request", ChangedFiles: []string{"src/mainboard/intel/archercity/romstage.c"}}, []string{"build.yaml", "qemu.yaml", "archercity.yaml"}},
+		{"mainboard push", Event{Repository: "9elements/coreboot-spr-sp", Branch: "master", Type: "push", ChangedFiles: []string{"src/mainboard/intel/archercity/romstage.c"}}, []string{"build.yaml", "qemu.yaml"}},
+		{"other branch", Event{Repository: "9elements/coreboot-spr-sp", Branch: "feature/x", Type: "pull_request", ChangedFiles: []string{"src/mainboard/intel/archercity/romstage.c"}}, []string{"build.yaml", "qemu.yaml"}},
+		{"other repository", Event{Repository: "coreboot/coreboot", Branch: "master", Type: "push", ChangedFiles: []string{"src/lib/main.c"}}, nil},
+		{"unknown changed files", Event{Repository: "9elements/contest", Branch: "master", Type: "push", ChangedFiles: nil}, []string{"build.yaml", "qemu.yaml"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := cd.JobTemplates(test.event); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+
+	// Without routes all templates of the flags are started
+	build, qemu := "build.yaml", "qemu.yaml"
+	cd = ClientDescriptor{Flags: Flags{FlagJobTemplate: []*string{&build, &qemu}}}
+	if got, want := cd.JobTemplates(Event{}), []string{build, qemu}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTargetManagers are the target managers that are registered in the ConTest server by default
+var defaultTargetManagers = []string{"CSVFileTargetManager", "TargetList"}
+
+// defaultTestFetchers are the test fetchers that are registered in the ConTest server by default
+var defaultTestFetchers = []string{"literal", "URI"}
+
+// Validation lists the plugins that are registered in the ConTest server. Servers that are built
+// with other plugins list them in the config file, the defaults are used for empty lists.
+type Validation struct {
+	TargetManagers []string // Names of the target managers
+	TestFetchers   []string // Names of the test fetchers
+}
+
+// targetManagers returns the configured target managers or the default ones
+func (val Validation) targetManagers() []string {
+	if len(val.TargetManagers) == 0 {
+		return defaultTargetManagers
+	}
+	return val.TargetManagers
+}
+
+// testFetchers returns the configured test fetchers or the default ones
+func (val Validation) testFetchers() []string {
+	if len(val.TestFetchers) == 0 {
+		return defaultTestFetchers
+	}
+	return val.TestFetchers
+}
+
+// leftoverPlaceholders are strings that remain in a job descriptor if a template was not substituted
+var leftoverPlaceholders = []string{"[[", "]]", "<no value>"}
+
+// ValidationError contains all problems that were found in a job descriptor
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid job descriptor:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// ValidateJobDescriptor checks the rendered job descriptor against the structure the ConTest server
+// expects, without sending it to the server. The types of the fields were already checked when it was
+// decoded, see DecodeJobDescriptor. The plugins are checked against the ones of the validation config.
+// All problems are returned as ValidationError.
+func ValidateJobDescriptor(desc *JobDescriptor, validation Validation) error {
+	v := &validator{validation: validation}
+	v.requireString("JobName", desc.JobName)
+	if desc.RunInterval != "" {
+		if _, err := time.ParseDuration(desc.RunInterval); err != nil {
+			v.problem("RunInterval", fmt.Sprintf("invalid duration %q", desc.RunInterval))
+		}
+	}
+
+	if len(desc.TestDescriptors) == 0 {
+		v.problem("TestDescriptors", "is required and must be a non-empty list")
+	}
+	for i, td := range desc.TestDescriptors {
+		v.testDescriptor(fmt.Sprintf("TestDescriptors[%d]", i), td)
+	}
+
+	if desc.Reporting == nil {
+		v.problem("Reporting", "is required and must be an object")
+	} else {
+		if len(desc.Reporting.RunReporters) == 0 {
+			v.problem("Reporting.RunReporters", "is required and must be a non-empty list")
+		}
+		for i, reporter := range desc.Reporting.RunReporters {
+			v.requireString(fmt.Sprintf("Reporting.RunReporters[%d].name", i), reporter.Name)
+		}
+		for i, reporter := range desc.Reporting.FinalReporters {
+			v.requireString(fmt.Sprintf("Reporting.FinalReporters[%d].name", i), reporter.Name)
+		}
+	}
+
+	v.descriptorPlaceholders(desc)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator collects the problems of a job descriptor
+type validator struct {
+	validation Validation
+	problems   []string
+}
+
+func (v *validator) problem(path string, msg string) {
+	v.problems = append(v.problems, path+": "+msg)
+}
+
+// testDescriptor checks the target manager and the test fetcher of a test descriptor
+func (v *validator) testDescriptor(path string, td TestDescriptor) {
+	targetManagers := v.validation.targetManagers()
+	if name := v.requireString(path+".TargetManagerName", td.TargetManagerName); name != "" && !known(targetManagers, name) {
+		v.problem(path+".TargetManagerName", fmt.Sprintf("unknown target manager %q, known are %s", name, strings.Join(targetManagers, ", ")))
+	}
+	if td.TargetManagerAcquireParameters == nil {
+		v.problem(path+".TargetManagerAcquireParameters", "is required and must be an object")
+	}
+
+	testFetchers := v.validation.testFetchers()
+	fetcher := v.requireString(path+".TestFetcherName", td.TestFetcherName)
+	if fetcher != "" && !known(testFetchers, fetcher) {
+		v.problem(path+".TestFetcherName", fmt.Sprintf("unknown test fetcher %q, known are %s", fetcher, strings.Join(testFetchers, ", ")))
+	}
+	params := td.TestFetcherFetchParameters
+	paramsPath := path + ".TestFetcherFetchParameters"
+	if params == nil {
+		v.problem(paramsPath, "is required and must be an object")
+		return
+	}
+	switch strings.ToLower(fetcher) {
+	case "literal":
+		v.requireString(paramsPath+".TestName", params.TestName)
+		if len(params.Steps) == 0 {
+			v.problem(paramsPath+".Steps", "is required and must be a non-empty list")
+		}
+		for i, step := range params.Steps {
+			v.step(fmt.Sprintf("%s.Steps[%d]", paramsPath, i), step)
+		}
+	case "uri":
+		v.requireString(paramsPath+".URI", params.URI)
+	}
+}
+
+// step checks that a test step has a name, a label and a list for every parameter
+func (v *validator) step(path string, step Step) {
+	v.requireString(path+".name", step.Name)
+	v.requireString(path+".label", step.Label)
+	for _, name := range sortedKeys(step.Parameters) {
+		if _, ok := step.Parameters[name].([]interface{}); !ok {
+			v.problem(path+".parameters."+name, "must be a list, e.g. [value]")
+		}
+	}
+}
+
+func (v *validator) requireString(path string, value string) string {
+	if value == "" {
+		v.problem(path, "is required and must be a non-empty string")
+	}
+	return value
+}
+
+// descriptorPlaceholders reports all fields of the descriptor that still contain template placeholders
+func (v *validator) descriptorPlaceholders(desc *JobDescriptor) {
+	v.placeholders("JobName", desc.JobName)
+	v.placeholders("RunInterval", desc.RunInterval)
+	for i, tag := range desc.Tags {
+		v.placeholders(fmt.Sprintf("Tags[%d]", i), tag)
+	}
+	for i, td := range desc.TestDescriptors {
+		path := fmt.Sprintf("TestDescriptors[%d]", i)
+		v.placeholders(path+".TargetManagerName", td.TargetManagerName)
+		v.placeholders(path+".TargetManagerAcquireParameters", td.TargetManagerAcquireParameters)
+		v.placeholders(path+".TargetManagerReleaseParameters", td.TargetManagerReleaseParameters)
+		v.placeholders(path+".TestFetcherName", td.TestFetcherName)
+		if params := td.TestFetcherFetchParameters; params != nil {
+			paramsPath := path + ".TestFetcherFetchParameters"
+			v.placeholders(paramsPath+".TestName", params.TestName)
+			v.placeholders(paramsPath+".URI", params.URI)
+			for j, step := range params.Steps {
+				stepPath := fmt.Sprintf("%s.Steps[%d]", paramsPath, j)
+				v.placeholders(stepPath+".name", step.Name)
+				v.placeholders(stepPath+".label", step.Label)
+				v.placeholders(stepPath+".parameters", step.Parameters)
+			}
+		}
+	}
+	if desc.Reporting != nil {
+		for i, reporter := range desc.Reporting.RunReporters {
+			v.placeholders(fmt.Sprintf("Reporting.RunReporters[%d].name", i), reporter.Name)
+			v.placeholders(fmt.Sprintf("Reporting.RunReporters[%d].parameters", i), reporter.Parameters)
+		}
+		for i, reporter := range desc.Reporting.FinalReporters {
+			v.placeholders(fmt.Sprintf("Reporting.FinalReporters[%d].name", i), reporter.Name)
+			v.placeholders(fmt.Sprintf("Reporting.FinalReporters[%d].parameters", i), reporter.Parameters)
+		}
+	}
+}
+
+// placeholders reports all strings that still contain template placeholders
+func (v *validator) placeholders(path string, value interface{}) {
+	switch val := value.(type) {
+	case string:
+		for _, placeholder := range leftoverPlaceholders {
+			if strings.Contains(val, placeholder) {
+				v.problem(path, fmt.Sprintf("contains the leftover placeholder %q in %q", placeholder, val))
+				return
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			v.placeholders(fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(val) {
+			v.placeholders(join(path, key), val[key])
+		}
+	}
+}
+
+// known returns true if the name is in the list, the ConTest plugin names are case-insensitive
+func known(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of the object in order, so the problems are reported in a stable order
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
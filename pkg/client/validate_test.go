@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const validJobDescriptor = `{
+    "JobName": "Build Test",
+    "Runs": 1,
+    "RunInterval": "60s",
+    "Tags": ["coreboot"],
+    "TestDescriptors": [
+        {
+            "TargetManagerName": "csvfiletargetmanager",
+            "TargetManagerAcquireParameters": {"FileURI": "hosts.csv"},
+            "TargetManagerReleaseParameters": null,
+            "TestFetcherName": "literal",
+            "TestFetcherFetchParameters": {
+                "TestName": "Build",
+                "Steps": [
+                    {"name": "cmd", "label": "Checkout", "parameters": {"executable": ["git"], "args": ["checkout", "0123456"]}}
+                ]
+            }
+        }
+    ],
+    "Reporting": {
+        "RunReporters": [{"name": "TargetSuccess", "parameters": {"SuccessExpression": "=100%"}}]
+    }
+}`
+
+// Test for ValidateJobDescriptor, if all problems of the descriptor are reported
+func TestValidateJobDescriptor(t *testing.T) {
+	desc, err := DecodeJobDescriptor("build.json", []byte(validJobDescriptor), false)
+	if err != nil {
+		t.Fatalf("DecodeJobDescriptor failed: %v", err)
+	}
+	if err := ValidateJobDescriptor(desc, Validation{}); err != nil {
+		t.Errorf("got %v want no error", err)
+	}
+
+	invalid := `{
+    "RunInterval": "soon",
+    "TestDescriptors": [
+        {
+            "TargetManagerName": "Unknown",
+            "TargetManagerAcquireParameters": {},
+            "TestFetcherName": "literal",
+            "TestFetcherFetchParameters": {
+                "TestName": "Build",
+                "Steps": [
+                    {"name": "cmd", "parameters": {"executable": "git", "args": ["checkout", "[[ .SHA ]]"]}}
+                ]
+            }
+        }
+    ]
+}`
+	want := []string{
+		"JobName: is required and must be a non-empty string",
+		`RunInterval: invalid duration "soon"`,
+		`TestDescriptors[0].TargetManagerName: unknown target manager "Unknown", known are CSVFileTargetManager, TargetList`,
+		"TestDescriptors[0].TestFetcherFetchParameters.Steps[0].label: is required and must be a non-empty string",
+		"TestDescriptors[0].TestFetcherFetchParameters.Steps[0].parameters.executable: must be a list, e.g. [value]",
+		"Reporting: is required and must be an object",
+		`TestDescriptors[0].TestFetcherFetchParameters.Steps[0].parameters.args[1]: contains the leftover placeholder "[[" in "[[ .SHA ]]"`,
+	}
+	desc, err = DecodeJobDescriptor("build.json", []byte(invalid), false)
+	if err != nil {
+		t.Fatalf("DecodeJobDescriptor failed: %v", err)
+	}
+	err = ValidateJobDescriptor(desc, Validation{})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v want a ValidationError", err)
+	}
+	if !reflect.DeepEqual(validationErr.Problems, want) {
+		t.Errorf("got %q want %q", validationErr.Problems, want)
+	}
+
+	// Servers that are built with other plugins configure them
+	desc.TestDescriptors[0].TargetManagerName = "Unknown"
+	err = ValidateJobDescriptor(desc, Validation{TargetManagers: []string{"unknown"}, TestFetchers: []string{"Literal"}})
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) != len(want)-1 {
+		t.Errorf("got %v want the problems without the unknown target manager", err)
+	}
+}
@@ -14,18 +14,26 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// ForgeGithub, ForgeGitlab, ForgeGerrit and ForgeGitea identify the forge a webhook was received from
+const (
+	ForgeGithub = "github"
+	ForgeGitlab = "gitlab"
+	ForgeGerrit = "gerrit"
+	ForgeGitea  = "gitea"
+)
+
 type TestAPI struct {
 }
 
 type Github interface {
-	EditGithubStatus(ctx context.Context, state string, targeturl string, description string, sha string) error
+	EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error
 }
 
 type Slack interface {
 	MsgToSlack(msg string) error
 }
 
-func (t TestAPI) EditGithubStatus(ctx context.Context, state string, targeturl string, description string, sha string) error {
+func (t TestAPI) EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error {
 	// Getting env variable GH_TOKEN
 	githubToken := os.Getenv("GITHUB_TOKEN")
 
@@ -40,15 +48,21 @@ func (t TestAPI) EditGithubStatus(ctx context.Context, state string, targeturl s
 		return fmt.Errorf("the github client has not set up")
 	}
 
+	// The repository the status belongs to has to be known
+	if owner == "" || repo == "" {
+		return fmt.Errorf("the repository owner and name have to be set")
+	}
+
 	// If the state is different to the possible github states, error
 	if state != "error" && state != "failure" && state != "pending" && state != "success" {
 		return fmt.Errorf("state has no correct value")
 	}
 
 	// If the targetURL is not empty and wrong formatted, error
-	_, err := url.ParseRequestURI(targeturl)
-	if err != nil {
-		return fmt.Errorf("TargetURL of the results is not formatted right! GithubStatus could not be edited")
+	if targeturl != "" {
+		if _, err := url.ParseRequestURI(targeturl); err != nil {
+			return fmt.Errorf("TargetURL of the results is not formatted right! GithubStatus could not be edited")
+		}
 	}
 	// Check if sha is a correct formatted sha1 hash else, error
 	match, err := regexp.MatchString("[a-f0-9]{40}", sha)
@@ -6,6 +6,8 @@ import (
 )
 
 const (
+	owner       = "9elements"
+	repo        = "coreboot-spr-sp"
 	state       = "pending"
 	targeturl   = "https://www.test.com/"
 	description = "test"
@@ -19,7 +21,7 @@ func TestClientApi(t *testing.T) {
 		Test := TestAPI{}
 		var ctx context.Context
 
-		err := Test.EditGithubStatus(ctx, state, targeturl, description, sha)
+		err := Test.EditGithubStatus(ctx, owner, repo, state, targeturl, description, "", sha)
 		if err != nil {
 			t.Errorf("function 'EditGithubStatus' returned an error: %w", err)
 		}
@@ -0,0 +1,54 @@
+package clientapi
+
+import (
+	"context"
+	"strings"
+)
+
+// Slash commands that are accepted in the comments of pull requests
+const (
+	CommandRetest = "retest" // Start the jobs of the templates that failed for the head commit again
+	CommandCancel = "cancel" // Stop the running jobs of the pull request
+	CommandRun    = "run"    // Start the job of the template in the argument, e.g. /run boot.yaml
+)
+
+// Command is a slash command of a comment on a pull request
+type Command struct {
+	Name          string   // CommandRetest, CommandCancel or CommandRun
+	Args          []string // Arguments of the command, the job template of /run
+	User          string   // Login of the user that wrote the comment
+	CommentID     int64    // ID of the comment, the command is acknowledged with a reaction on it
+	ReviewComment bool     // True if the comment is a review comment on the diff of the pull request
+}
+
+// Commander is implemented by the status reporters of forges that accept slash commands in pull requests
+type Commander interface {
+	// HasWriteAccess returns true if the user may push to the repository, only they may run commands
+	HasWriteAccess(ctx context.Context, owner string, repo string, user string) (bool, error)
+	// PullRequest returns the event of the head commit of the pull request, the commands are run for it
+	PullRequest(ctx context.Context, owner string, repo string, number int) (*Event, error)
+	// Acknowledge reacts to the comment of the command and posts the reply to the pull request, if it is not empty
+	Acknowledge(ctx context.Context, owner string, repo string, number int, command Command, accepted bool, reply string) error
+}
+
+// ParseCommand returns the first slash command in the body of a comment. Lines that are no commands,
+// e.g. the quote of a command in a reply, are ignored.
+func ParseCommand(body string) (Command, bool) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], "/")
+		switch name {
+		case CommandRetest, CommandCancel:
+			return Command{Name: name}, true
+		case CommandRun:
+			if len(fields) < 2 {
+				continue
+			}
+			return Command{Name: name, Args: fields[1:]}, true
+		}
+	}
+	return Command{}, false
+}
@@ -0,0 +1,32 @@
+package clientapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test for ParseCommand, if the first slash command of a comment is found
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		want  Command
+		found bool
+	}{
+		{"retest", "/retest", Command{Name: CommandRetest}, true},
+		{"cancel after text", "the boot hangs again\r\n/cancel\n", Command{Name: CommandCancel}, true},
+		{"run", "/run archercity.yaml", Command{Name: CommandRun, Args: []string{"archercity.yaml"}}, true},
+		{"run without template", "/run\n/retest", Command{Name: CommandRetest}, true},
+		{"quoted command", "> /retest\nthat did not help", Command{}, false},
+		{"unknown command", "/approve", Command{}, false},
+		{"no command", "LGTM", Command{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := ParseCommand(test.body)
+			if found != test.found || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, %v want %+v, %v", got, found, test.want, test.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package clientapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// gerritLabel is the default label that is voted on
+	gerritLabel = "Verified"
+	// gerritXSSIPrefix is prepended to the JSON responses of the Gerrit REST API
+	gerritXSSIPrefix = ")]}'"
+)
+
+// gerritMagicFiles are listed with the files of a revision, but they are not part of the commit
+var gerritMagicFiles = map[string]bool{"/COMMIT_MSG": true, "/MERGE_LIST": true, "/PATCHSET_LEVEL": true}
+
+// gerrit is the configured Gerrit instance
+var gerrit = struct {
+	lock    sync.Mutex
+	baseURL string
+	label   string
+}{label: gerritLabel}
+
+// SetupGerrit configures the base URL of the Gerrit instance, e.g. https://review.coreboot.org,
+// and the label that is voted on, Verified if it is empty
+func SetupGerrit(baseURL string, label string) {
+	gerrit.lock.Lock()
+	defer gerrit.lock.Unlock()
+	gerrit.baseURL = strings.TrimSuffix(baseURL, "/")
+	if label != "" {
+		gerrit.label = label
+	}
+}
+
+// gerritRevisions collects the results of the jobs per revision, they are posted as a single review
+var gerritRevisions = struct {
+	lock      sync.Mutex
+	revisions map[string]*gerritRevision
+}{revisions: make(map[string]*gerritRevision)}
+
+// gerritRevision contains the results of the jobs of a revision that finished so far
+type gerritRevision struct {
+	running  map[string]bool // Status contexts of the jobs that did not finish yet
+	messages []string        // Messages of the finished jobs
+	vote     int             // -1 if a job failed, 1 if all finished jobs succeeded
+}
+
+// startGerritJob records a job of the revision that is running, the review waits for it
+func startGerritJob(project string, sha string, statusContext string) {
+	gerritRevisions.lock.Lock()
+	defer gerritRevisions.lock.Unlock()
+	key := project + "@" + sha
+	revision := gerritRevisions.revisions[key]
+	if revision == nil {
+		revision = &gerritRevision{running: make(map[string]bool), vote: 1}
+		gerritRevisions.revisions[key] = revision
+	}
+	revision.running[statusContext] = true
+}
+
+// finishGerritJob records the result of a job of the revision. If no other job of the revision is running,
+// the messages and the vote of all jobs are returned and true. Jobs whose start was not recorded,
+// e.g. before a restart of the client, are posted on their own.
+func finishGerritJob(project string, sha string, statusContext string, message string, vote int) ([]string, int, bool) {
+	gerritRevisions.lock.Lock()
+	defer gerritRevisions.lock.Unlock()
+	key := project + "@" + sha
+	revision := gerritRevisions.revisions[key]
+	if revision == nil {
+		revision = &gerritRevision{running: make(map[string]bool), vote: 1}
+	}
+	return revision.finish(key, statusContext, message, vote)
+}
+
+// abandonGerritJob finishes a job of the revision that is still running without a vote, e.g. since no final status
+// was reported for it. The result is the one of finishGerritJob, nothing is returned if the job is not running.
+func abandonGerritJob(project string, sha string, statusContext string) ([]string, int, bool) {
+	gerritRevisions.lock.Lock()
+	defer gerritRevisions.lock.Unlock()
+	key := project + "@" + sha
+	revision := gerritRevisions.revisions[key]
+	if revision == nil || !revision.running[statusContext] {
+		return nil, 0, false
+	}
+	return revision.finish(key, statusContext, statusContext+"\n\nResult: no final status was reported", 0)
+}
+
+// finish records the result of the job, gerritRevisions has to be locked
+func (revision *gerritRevision) finish(key string, statusContext string, message string, vote int) ([]string, int, bool) {
+	delete(revision.running, statusContext)
+	revision.messages = append(revision.messages, message)
+	if vote < revision.vote {
+		revision.vote = vote
+	}
+	if len(revision.running) > 0 {
+		gerritRevisions.revisions[key] = revision
+		return nil, 0, false
+	}
+	delete(gerritRevisions.revisions, key)
+	return revision.messages, revision.vote, true
+}
+
+// GerritAPI posts reviews to Gerrit. It implements the Github interface: the final commit statuses of the jobs of a
+// revision are posted as a single review with a vote on the Verified label and the messages linking the reports.
+// The review is posted after the last running job of the revision finished, pending statuses are not posted.
+// Jobs that never report a final status are added to the review when their delivery finished, see FinishStatuses.
+// The account is read from the GERRIT_USERNAME and GERRIT_PASSWORD (HTTP password) env variables.
+type GerritAPI struct {
+	BaseURL    string       // Base URL of Gerrit, the configured instance is used if it is empty
+	Username   string       // Account that posts the reviews, GERRIT_USERNAME is used if it is empty
+	Password   string       // HTTP password of the account, GERRIT_PASSWORD is used if it is empty
+	Label      string       // Label that is voted on, the configured label is used if it is empty
+	HTTPClient *http.Client // HTTP client of the requests, a client with a timeout of 30s is used if it is nil
+}
+
+// gerritReview is the input of the review of a revision
+type gerritReview struct {
+	Message string         `json:"message"`
+	Labels  map[string]int `json:"labels,omitempty"`
+	Tag     string         `json:"tag"`
+}
+
+// gerritChange is the part of the ChangeInfo of the Gerrit REST API that is needed for the reviews.
+// The labels are the ones of the revision whose review was requested.
+type gerritChange struct {
+	ID     string `json:"id"`
+	Labels map[string]struct {
+		All []struct {
+			Username string `json:"username"`
+			Value    int    `json:"value"`
+		} `json:"all"`
+	} `json:"labels"`
+}
+
+// EditGithubStatus records the status of the job for the revision sha of a change in the Gerrit project "owner/repo".
+// After the last running job of the revision finished, a review is posted. It votes -1 if a job failed and +1 if
+// all jobs succeeded, unless a job of an earlier review of the same revision already voted -1 with our account.
+func (g GerritAPI) EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error {
+	if !shaPattern.MatchString(sha) {
+		return fmt.Errorf("the SHA is not a correct formatted sha1 hash")
+	}
+	project := repo
+	if owner != "" {
+		project = owner + "/" + repo
+	}
+	var vote int
+	switch state {
+	case "pending":
+		startGerritJob(project, sha, statusContext)
+		return nil
+	case "success":
+		vote = 1
+	case "failure", "error":
+		vote = -1
+	default:
+		return fmt.Errorf("state has no correct value")
+	}
+	message := strings.Join(strings.Fields(fmt.Sprintf("%s %s %s", statusContext, targeturl, description)), " ")
+	messages, vote, finished := finishGerritJob(project, sha, statusContext, fmt.Sprintf("%s\n\nResult: %s", message, state), vote)
+	if !finished {
+		return nil
+	}
+	return g.postReview(ctx, project, sha, messages, vote)
+}
+
+// FinishStatuses posts the review of the revision sha of the Gerrit project "owner/repo" after the jobs of a delivery
+// finished, even if no final status was reported for some of them, e.g. since they failed permanently.
+// The jobs of the status contexts that are still running are added to the review without a vote.
+func (g GerritAPI) FinishStatuses(ctx context.Context, owner string, repo string, sha string, contexts []string) error {
+	project := repo
+	if owner != "" {
+		project = owner + "/" + repo
+	}
+	for _, statusContext := range contexts {
+		if messages, vote, finished := abandonGerritJob(project, sha, statusContext); finished {
+			return g.postReview(ctx, project, sha, messages, vote)
+		}
+	}
+	return nil
+}
+
+// postReview posts the messages of the jobs of the revision as a single review with the vote, no label is voted on if it is 0
+func (g GerritAPI) postReview(ctx context.Context, project string, sha string, messages []string, vote int) error {
+	// Find the change of the revision
+	var changes []gerritChange
+	query := url.Values{"q": {"commit:" + sha + " project:" + project}}
+	if err := g.request(ctx, http.MethodGet, "/a/changes/?"+query.Encode(), nil, &changes); err != nil {
+		return fmt.Errorf("could not find the gerrit change of %s: %w", sha, err)
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("there is no gerrit change with the revision %s in %s", sha, project)
+	}
+	path := fmt.Sprintf("/a/changes/%s/revisions/%s/review", url.PathEscape(changes[0].ID), sha)
+
+	// A failed job of an earlier review of the revision is not overruled
+	label := g.label()
+	if vote > 0 {
+		var revision gerritChange
+		if err := g.request(ctx, http.MethodGet, path, nil, &revision); err != nil {
+			return fmt.Errorf("could not get the votes on the revision %s: %w", sha, err)
+		}
+		for _, approval := range revision.Labels[label].All {
+			if approval.Username == g.username() && approval.Value < 0 {
+				vote = 0
+			}
+		}
+	}
+
+	review := gerritReview{Message: strings.Join(messages, "\n\n"), Tag: "autogenerated:contest"}
+	if vote != 0 {
+		review.Labels = map[string]int{label: vote}
+	}
+	err := g.request(ctx, http.MethodPost, path, review, nil)
+	// Outdated patchsets can not be voted on anymore, the message is still posted
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusConflict && review.Labels != nil {
+		review.Labels = nil
+		err = g.request(ctx, http.MethodPost, path, review, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("could not post the gerrit review of %s: %w", sha, err)
+	}
+	return nil
+}
+
+// ReportStatus posts a review for the final states of the job, pending and running jobs are not posted
+func (g GerritAPI) ReportStatus(ctx context.Context, status Status) error {
+	return g.EditGithubStatus(ctx, status.Owner, status.Repo, githubState(status.State), status.TargetURL, status.Context,
+		status.Description, status.SHA)
+}
+
+// ListPullRequestFiles returns the files that are changed by the revision sha of the change
+func (g GerritAPI) ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, sha string) ([]string, error) {
+	return g.ListChangedFiles(ctx, number, sha)
+}
+
+// ListChangedFiles returns the sorted list of files that are changed by the revision of the change
+func (g GerritAPI) ListChangedFiles(ctx context.Context, change int, revision string) ([]string, error) {
+	var files map[string]json.RawMessage
+	path := fmt.Sprintf("/a/changes/%d/revisions/%s/files", change, url.PathEscape(revision))
+	if err := g.request(ctx, http.MethodGet, path, nil, &files); err != nil {
+		return nil, fmt.Errorf("could not list the changed files of change %d: %w", change, err)
+	}
+	changed := make([]string, 0, len(files))
+	for file := range files {
+		if !gerritMagicFiles[file] {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// GetFileContent returns the content of the file in the Gerrit project "owner/repo" at the given commit.
+// If the file does not exist, ErrNotFound is returned.
+func (g GerritAPI) GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error) {
+	project := repo
+	if owner != "" {
+		project = owner + "/" + repo
+	}
+	var encoded []byte
+	apiPath := fmt.Sprintf("/a/projects/%s/commits/%s/files/%s/content", url.PathEscape(project), url.PathEscape(ref),
+		url.PathEscape(path))
+	err := g.request(ctx, http.MethodGet, apiPath, nil, &rawBody{&encoded})
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return nil, fmt.Errorf("%s in %s at %s: %w", path, project, ref, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s from %s at %s: %w", path, project, ref, err)
+	}
+	// Gerrit returns the content base64 encoded
+	content, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s from %s at %s: %w", path, project, ref, err)
+	}
+	return content, nil
+}
+
+func (g GerritAPI) label() string {
+	if g.Label != "" {
+		return g.Label
+	}
+	gerrit.lock.Lock()
+	defer gerrit.lock.Unlock()
+	return gerrit.label
+}
+
+func (g GerritAPI) username() string {
+	if g.Username != "" {
+		return g.Username
+	}
+	return os.Getenv("GERRIT_USERNAME")
+}
+
+// request sends the JSON body to the Gerrit REST API and decodes the JSON response into v, if it is not nil
+func (g GerritAPI) request(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		gerrit.lock.Lock()
+		baseURL = gerrit.baseURL
+		gerrit.lock.Unlock()
+	}
+	if baseURL == "" {
+		return fmt.Errorf("the URL of gerrit is not configured")
+	}
+	password := g.Password
+	if password == "" {
+		password = os.Getenv("GERRIT_PASSWORD")
+	}
+	api := restAPI{baseURL: baseURL, httpClient: g.HTTPClient, jsonPrefix: gerritXSSIPrefix, authorize: func(req *http.Request) {
+		req.SetBasicAuth(g.username(), password)
+	}}
+	return api.request(ctx, method, path, body, v)
+}
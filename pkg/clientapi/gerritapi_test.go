@@ -0,0 +1,167 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const gerritTestSHA = "a94a8fe5ccb19ba61c4c0873daa391e9872fbbd3"
+
+// Test for GerritAPI, if the final commit statuses are posted as reviews with a Verified vote to a fake Gerrit
+func TestGerritAPI(t *testing.T) {
+	var got *gerritReview
+	vote := 0
+	current := true
+	review := "/a/changes/coreboot~main~I8473b95934b5732ac55d26311a706c9c2bde9940/revisions/" + gerritTestSHA + "/review"
+	server := newFakeForge(func(r *http.Request) bool {
+		user, password, _ := r.BasicAuth()
+		return user == "contest" && password == "secret"
+	}, map[string]http.HandlerFunc{
+		"GET /a/changes/": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("q") != "commit:"+gerritTestSHA+" project:coreboot" {
+				fmt.Fprint(w, ")]}'\n[]")
+				return
+			}
+			fmt.Fprint(w, ")]}'\n"+`[{"id": "coreboot~main~I8473b95934b5732ac55d26311a706c9c2bde9940"}]`)
+		},
+		"GET " + review: func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, ")]}'\n"+`{"id": "coreboot~main~I8473b95934b5732ac55d26311a706c9c2bde9940",
+				"labels": {"Verified": {"all": [{"username": "contest", "value": %d}]}}}`, vote)
+		},
+		"POST " + review: func(w http.ResponseWriter, r *http.Request) {
+			got = &gerritReview{}
+			if !decodeRequest(w, r, got) {
+				return
+			}
+			if got.Labels != nil && !current {
+				http.Error(w, "cannot post review on non-current patch set", http.StatusConflict)
+				return
+			}
+			if got.Labels != nil {
+				vote = got.Labels["Verified"]
+			}
+			fmt.Fprint(w, ")]}'\n{}")
+		},
+		"GET /a/changes/12345/revisions/" + gerritTestSHA + "/files": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, ")]}'\n"+`{"/COMMIT_MSG": {}, "src/mainboard/romstage.c": {}, "Makefile.inc": {}}`)
+		},
+		"GET /a/projects/coreboot/commits/" + gerritTestSHA + "/files/.contest%2Fbuild.yaml/content": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "Sm9iTmFtZTogQnVpbGQgVGVzdA==")
+		},
+	})
+	defer server.Close()
+	gerrit := GerritAPI{BaseURL: server.URL, Username: "contest", Password: "secret", Label: "Verified"}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		state   string
+		current bool
+		want    map[string]int
+	}{
+		{"pending", "pending", true, nil},
+		{"success", "success", true, map[string]int{"Verified": 1}},
+		{"failure", "failure", true, map[string]int{"Verified": -1}},
+		{"success after failure", "success", true, nil},
+		{"outdated patchset", "error", false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got = nil
+			current = test.current
+			err := gerrit.EditGithubStatus(ctx, "", "coreboot", test.state, "https://reports.example.com/1", "Build Test. Test-Report:", "", gerritTestSHA)
+			if err != nil {
+				t.Fatalf("EditGithubStatus failed: %v", err)
+			}
+			if test.state == "pending" {
+				if got != nil {
+					t.Errorf("got %+v want no review", got)
+				}
+				return
+			}
+			if got == nil || !reflect.DeepEqual(got.Labels, test.want) {
+				t.Fatalf("got %+v want labels %v", got, test.want)
+			}
+			if want := "Build Test. Test-Report: https://reports.example.com/1\n\nResult: " + test.state; got.Message != want {
+				t.Errorf("got message %q want %q", got.Message, want)
+			}
+		})
+	}
+
+	t.Run("one review for all jobs of the revision", func(t *testing.T) {
+		got, vote, current = nil, 0, true
+		for _, statusContext := range []string{"Build Test. Test-Report:", "QEMU Boot Test. Test-Report:"} {
+			if err := gerrit.EditGithubStatus(ctx, "", "coreboot", "pending", "", statusContext, "", gerritTestSHA); err != nil {
+				t.Fatalf("EditGithubStatus failed: %v", err)
+			}
+		}
+		if err := gerrit.EditGithubStatus(ctx, "", "coreboot", "failure", "", "Build Test. Test-Report:", "", gerritTestSHA); err != nil {
+			t.Fatalf("EditGithubStatus failed: %v", err)
+		}
+		if got != nil {
+			t.Fatalf("got %+v want no review while a job is running", got)
+		}
+		if err := gerrit.EditGithubStatus(ctx, "", "coreboot", "success", "", "QEMU Boot Test. Test-Report:", "", gerritTestSHA); err != nil {
+			t.Fatalf("EditGithubStatus failed: %v", err)
+		}
+		want := "Build Test. Test-Report:\n\nResult: failure\n\nQEMU Boot Test. Test-Report:\n\nResult: success"
+		if got == nil || got.Labels["Verified"] != -1 || got.Message != want {
+			t.Errorf("got %+v want one review with the vote -1 and the message %q", got, want)
+		}
+	})
+	t.Run("job without a final status", func(t *testing.T) {
+		got, vote, current = nil, 0, true
+		for _, statusContext := range []string{"Build Test. Test-Report:", "QEMU Boot Test. Test-Report:"} {
+			if err := gerrit.EditGithubStatus(ctx, "", "coreboot", "pending", "", statusContext, "", gerritTestSHA); err != nil {
+				t.Fatalf("EditGithubStatus failed: %v", err)
+			}
+		}
+		if err := gerrit.EditGithubStatus(ctx, "", "coreboot", "success", "", "Build Test. Test-Report:", "", gerritTestSHA); err != nil {
+			t.Fatalf("EditGithubStatus failed: %v", err)
+		}
+		if got != nil {
+			t.Fatalf("got %+v want no review while a job is running", got)
+		}
+		contexts := []string{"Build Test. Test-Report:", "QEMU Boot Test. Test-Report:"}
+		if err := gerrit.FinishStatuses(ctx, "", "coreboot", gerritTestSHA, contexts); err != nil {
+			t.Fatalf("FinishStatuses failed: %v", err)
+		}
+		want := "Build Test. Test-Report:\n\nResult: success\n\nQEMU Boot Test. Test-Report:\n\nResult: no final status was reported"
+		if got == nil || got.Labels != nil || got.Message != want {
+			t.Errorf("got %+v want one review without a vote and the message %q", got, want)
+		}
+		if len(gerritRevisions.revisions) != 0 {
+			t.Errorf("got revisions %v want none", gerritRevisions.revisions)
+		}
+
+		// The review is only posted once
+		got = nil
+		if err := gerrit.FinishStatuses(ctx, "", "coreboot", gerritTestSHA, contexts); err != nil || got != nil {
+			t.Errorf("got %+v, %v want no review", got, err)
+		}
+	})
+	t.Run("unknown change", func(t *testing.T) {
+		if err := gerrit.EditGithubStatus(ctx, "", "flashrom", "success", "", "Build Test", "", gerritTestSHA); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("changed files", func(t *testing.T) {
+		files, err := gerrit.ListChangedFiles(ctx, 12345, gerritTestSHA)
+		if want := []string{"Makefile.inc", "src/mainboard/romstage.c"}; err != nil || !reflect.DeepEqual(files, want) {
+			t.Errorf("got %v, %v want %v", files, err, want)
+		}
+	})
+	t.Run("file content", func(t *testing.T) {
+		content, err := gerrit.GetFileContent(ctx, "", "coreboot", ".contest/build.yaml", gerritTestSHA)
+		if err != nil || string(content) != "JobName: Build Test" {
+			t.Errorf("got %q, %v want %q", content, err, "JobName: Build Test")
+		}
+		if _, err := gerrit.GetFileContent(ctx, "", "coreboot", ".contest/missing.yaml", gerritTestSHA); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v want %v", err, ErrNotFound)
+		}
+	})
+}
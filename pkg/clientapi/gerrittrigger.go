@@ -0,0 +1,131 @@
+package clientapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// MaxGerritPayload limits the size of the events of Gerrit
+const MaxGerritPayload = 1 << 20
+
+// gerritAccount is an account in the events of Gerrit
+type gerritAccount struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// gerritEvent is an event of Gerrit stream-events or the webhooks plugin, only patchset-created is handled
+type gerritEvent struct {
+	Type   string `json:"type"`
+	Change struct {
+		Project string        `json:"project"`
+		Branch  string        `json:"branch"`
+		Number  int           `json:"number"`
+		Subject string        `json:"subject"`
+		Owner   gerritAccount `json:"owner"`
+	} `json:"change"`
+	PatchSet struct {
+		Number   int           `json:"number"`
+		Revision string        `json:"revision"`
+		Parents  []string      `json:"parents"`
+		Ref      string        `json:"ref"`
+		Kind     string        `json:"kind"`
+		Uploader gerritAccount `json:"uploader"`
+	} `json:"patchSet"`
+}
+
+// GerritTrigger receives the events of the Gerrit webhooks plugin on the WebhookPath of the Gerrit config. The plugin
+// can not sign the events, the token query parameter of the configured URL has to match the GERRIT_SECRET env variable.
+type GerritTrigger struct {
+	Gerrit client.Gerrit
+}
+
+// Match returns true for webhooks that are received on the WebhookPath of Gerrit
+func (g GerritTrigger) Match(r *http.Request) bool {
+	return g.Gerrit.WebhookPath != "" && r.URL.Path == g.Gerrit.WebhookPath
+}
+
+// Parse validates the token of the webhook and returns the event of created patchsets
+func (g GerritTrigger) Parse(r *http.Request) (*Event, error) {
+	secret := os.Getenv("GERRIT_SECRET")
+	token := r.URL.Query().Get("token")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return nil, &RequestError{Code: http.StatusUnauthorized, Msg: "invalid webhook token", Err: fmt.Errorf("invalid token of the gerrit webhook")}
+	}
+	payload, err := readPayload(r, MaxGerritPayload)
+	if err != nil {
+		return nil, err
+	}
+	e, err := ParseGerritEvent(g.Gerrit, payload)
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "could not parse webhook", Err: err}
+	}
+	return e, nil
+}
+
+// ParseGerritEvent maps the patchset-created events of Gerrit stream-events and the webhooks plugin to events.
+// Patchsets are pull requests, other events and patchsets that only change the commit message return no event.
+func ParseGerritEvent(gerrit client.Gerrit, payload []byte) (*Event, error) {
+	var e gerritEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	if e.Type != "patchset-created" {
+		return nil, nil
+	}
+	if e.PatchSet.Kind == "NO_CODE_CHANGE" {
+		return nil, nil
+	}
+	if e.Change.Number == 0 || e.PatchSet.Revision == "" {
+		return nil, fmt.Errorf("the patchset-created event has no change number or revision")
+	}
+	return gerritPatchSetData(gerrit, e), nil
+}
+
+// gerritPatchSetData returns the change, the patchset and the project of the event. The changed
+// files are not part of the event, they are requested by the worker.
+func gerritPatchSetData(gerrit client.Gerrit, e gerritEvent) *Event {
+	owner, name := splitProjectPath(e.Change.Project)
+	event := &Event{
+		Forge: ForgeGerrit,
+		// The same patchset may be received over SSH and from the webhooks plugin, it is only processed once
+		DeliveryID: fmt.Sprintf("gerrit-%d-%d", e.Change.Number, e.PatchSet.Number),
+		Type:       "pull_request",
+		SHA:        e.PatchSet.Revision,
+		// New patchsets of the change supersede the jobs of the older ones, their refs share the prefix of the change
+		Branch:     fmt.Sprintf("refs/changes/%02d/%d", e.Change.Number%100, e.Change.Number),
+		Ref:        e.PatchSet.Ref,
+		BaseBranch: e.Change.Branch,
+		RepoOwner:  owner,
+		RepoName:   name,
+		PRNumber:   e.Change.Number,
+		PatchSet:   e.PatchSet.Number,
+		Title:      e.Change.Subject,
+		Author:     e.PatchSet.Uploader.Username,
+	}
+	if len(e.PatchSet.Parents) > 0 {
+		event.BaseSHA = e.PatchSet.Parents[0]
+	}
+	if gerrit.URL != "" {
+		event.CloneURL = strings.TrimSuffix(gerrit.URL, "/") + "/" + e.Change.Project
+	}
+	if userHost, port, err := gerrit.SSHAddress(); err == nil {
+		host := userHost[strings.LastIndex(userHost, "@")+1:]
+		event.SSHURL = fmt.Sprintf("ssh://%s/%s", net.JoinHostPort(host, port), e.Change.Project)
+	}
+	// Patchsets are uploaded to the project itself, but by any account that may upload them,
+	// they are not trusted like pushes to the repository
+	event.BaseSSHURL = event.SSHURL
+	event.BaseCloneURL = event.CloneURL
+	if event.Author == "" {
+		event.Author = e.Change.Owner.Username
+	}
+	return event
+}
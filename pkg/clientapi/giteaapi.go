@@ -0,0 +1,145 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// giteaPageSize is the number of changed files that are requested per page
+const giteaPageSize = 50
+
+// gitea are the configured Gitea instances
+var gitea = struct {
+	lock      sync.Mutex
+	instances []client.Gitea
+}{}
+
+// SetupGitea configures the Gitea and Forgejo instances, the instance of a repository is selected by its Repositories
+func SetupGitea(instances []client.Gitea) {
+	gitea.lock.Lock()
+	defer gitea.lock.Unlock()
+	gitea.instances = instances
+}
+
+// GiteaAPI sets commit statuses on Gitea and Forgejo. It implements the Github interface, Gitea has the same
+// commit states as GitHub.
+type GiteaAPI struct {
+	BaseURL    string       // Base URL of Gitea, the instance that hosts the repository is used if it is empty
+	Token      string       // Access token, the token of the instance that hosts the repository is used if it is empty
+	HTTPClient *http.Client // HTTP client of the requests, a client with a timeout of 30s is used if it is nil
+}
+
+// EditGithubStatus sets the commit status of the Gitea repository "owner/repo"
+func (g GiteaAPI) EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error {
+	// The repository the status belongs to has to be known
+	if owner == "" || repo == "" {
+		return fmt.Errorf("the repository owner and name have to be set")
+	}
+	if state != "error" && state != "failure" && state != "pending" && state != "success" {
+		return fmt.Errorf("state has no correct value")
+	}
+	if targeturl != "" {
+		if _, err := url.ParseRequestURI(targeturl); err != nil {
+			return fmt.Errorf("TargetURL of the results is not formatted right! GiteaStatus could not be edited")
+		}
+	}
+	if !shaPattern.MatchString(sha) {
+		return fmt.Errorf("the SHA is not a correct formatted sha1 hash")
+	}
+
+	status := struct {
+		State       string `json:"state"`
+		Context     string `json:"context,omitempty"`
+		TargetURL   string `json:"target_url,omitempty"`
+		Description string `json:"description,omitempty"`
+	}{state, statusContext, targeturl, description}
+	path := fmt.Sprintf("/api/v1/repos/%s/%s/statuses/%s", url.PathEscape(owner), url.PathEscape(repo), sha)
+	if err := g.request(ctx, owner, repo, http.MethodPost, path, status, nil); err != nil {
+		return fmt.Errorf("could not set the gitea status of %s/%s: %w", owner, repo, err)
+	}
+	return nil
+}
+
+// ReportStatus sets the commit status, Gitea has no running state, running jobs are pending
+func (g GiteaAPI) ReportStatus(ctx context.Context, status Status) error {
+	return g.EditGithubStatus(ctx, status.Owner, status.Repo, githubState(status.State), status.TargetURL, status.Context,
+		status.Description, status.SHA)
+}
+
+// ListPullRequestFiles returns the names of the files that are changed by the pull request
+func (g GiteaAPI) ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, sha string) ([]string, error) {
+	return g.ListChangedFiles(ctx, owner, repo, number)
+}
+
+// ListChangedFiles returns the names of the files that are changed by the pull request
+func (g GiteaAPI) ListChangedFiles(ctx context.Context, owner string, repo string, number int) ([]string, error) {
+	files := []string{}
+	for page := 1; ; page++ {
+		var changed []struct {
+			Filename string `json:"filename"`
+		}
+		path := fmt.Sprintf("/api/v1/repos/%s/%s/pulls/%d/files?page=%d&limit=%d", url.PathEscape(owner), url.PathEscape(repo),
+			number, page, giteaPageSize)
+		if err := g.request(ctx, owner, repo, http.MethodGet, path, nil, &changed); err != nil {
+			return nil, fmt.Errorf("could not list the changed files of the pull request %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, file := range changed {
+			files = append(files, file.Filename)
+		}
+		if len(changed) < giteaPageSize {
+			return files, nil
+		}
+	}
+}
+
+// GetFileContent returns the content of the file in the Gitea repository at the given ref, e.g. a commit SHA.
+// If the file does not exist, ErrNotFound is returned.
+func (g GiteaAPI) GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error) {
+	var content []byte
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	apiPath := fmt.Sprintf("/api/v1/repos/%s/%s/raw/%s?ref=%s", url.PathEscape(owner), url.PathEscape(repo),
+		strings.Join(segments, "/"), url.QueryEscape(ref))
+	err := g.request(ctx, owner, repo, http.MethodGet, apiPath, nil, &rawBody{&content})
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return nil, fmt.Errorf("%s in %s/%s at %s: %w", path, owner, repo, ref, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s from %s/%s at %s: %w", path, owner, repo, ref, err)
+	}
+	return content, nil
+}
+
+// request sends the JSON body to the Gitea API of the instance that hosts the repository and decodes the JSON
+// response into v, if it is not nil
+func (g GiteaAPI) request(ctx context.Context, owner string, repo string, method string, path string, body interface{}, v interface{}) error {
+	baseURL, token := g.BaseURL, g.Token
+	if baseURL == "" {
+		gitea.lock.Lock()
+		instance, found := client.GiteaFor(gitea.instances, owner+"/"+repo, "")
+		gitea.lock.Unlock()
+		if !found {
+			return fmt.Errorf("no gitea instance is configured for %s/%s", owner, repo)
+		}
+		baseURL = instance.URL
+		if token == "" {
+			token = instance.Token()
+		}
+	}
+	api := restAPI{baseURL: baseURL, httpClient: g.HTTPClient, authorize: func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+	}}
+	return api.request(ctx, method, path, body, v)
+}
@@ -0,0 +1,100 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+const giteaTestSHA = "a94a8fe5ccb19ba61c4c0873daa391e9872fbbd3"
+
+// Test for GiteaAPI, if the commit statuses are set on the Gitea instance that hosts the repository
+func TestGiteaAPI(t *testing.T) {
+	var got map[string]string
+	newServer := func(token string) *httptest.Server {
+		return newFakeForge(func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "token "+token
+		}, map[string]http.HandlerFunc{
+			"POST /api/v1/repos/firmware/coreboot/statuses/" + giteaTestSHA: func(w http.ResponseWriter, r *http.Request) {
+				got = map[string]string{"instance": token}
+				if decodeRequest(w, r, &got) {
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprint(w, `{"id": 1}`)
+				}
+			},
+			"GET /api/v1/repos/firmware/coreboot/pulls/7/files": func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") != "1" {
+					fmt.Fprint(w, `[]`)
+					return
+				}
+				fmt.Fprint(w, `[{"filename": "Makefile.inc"}, {"filename": "src/mainboard/romstage.c"}]`)
+			},
+			"GET /api/v1/repos/firmware/coreboot/raw/.contest/build.yaml": func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("ref") != giteaTestSHA {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, "JobName: Build Test")
+			},
+		})
+	}
+	internal := newServer("internal-token")
+	defer internal.Close()
+	mirror := newServer("mirror-token")
+	defer mirror.Close()
+	os.Setenv("TEST_GITEA_INTERNAL", "internal-token")
+	defer os.Unsetenv("TEST_GITEA_INTERNAL")
+	os.Setenv("TEST_GITEA_MIRROR", "mirror-token")
+	defer os.Unsetenv("TEST_GITEA_MIRROR")
+	SetupGitea([]client.Gitea{
+		{URL: internal.URL, Repositories: []string{"firmware/coreboot"}, TokenEnv: "TEST_GITEA_INTERNAL"},
+		{URL: mirror.URL, TokenEnv: "TEST_GITEA_MIRROR"},
+	})
+	defer SetupGitea(nil)
+	ctx := context.Background()
+
+	t.Run("status", func(t *testing.T) {
+		got = nil
+		err := GiteaAPI{}.EditGithubStatus(ctx, "firmware", "coreboot", "success", "https://reports.example.com/1", "Build Test. Test-Report:", "", giteaTestSHA)
+		if err != nil {
+			t.Fatalf("EditGithubStatus failed: %v", err)
+		}
+		want := map[string]string{"instance": "internal-token", "state": "success", "context": "Build Test. Test-Report:", "target_url": "https://reports.example.com/1"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+	t.Run("other repository", func(t *testing.T) {
+		// The repository is not hosted on the internal instance, the mirror does not know it
+		if err := (GiteaAPI{}).EditGithubStatus(ctx, "9elements", "contest", "success", "", "Build Test", "", giteaTestSHA); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("unknown state", func(t *testing.T) {
+		if err := (GiteaAPI{}).EditGithubStatus(ctx, "firmware", "coreboot", "skipped", "", "Build Test", "", giteaTestSHA); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("changed files", func(t *testing.T) {
+		files, err := GiteaAPI{}.ListChangedFiles(ctx, "firmware", "coreboot", 7)
+		if want := []string{"Makefile.inc", "src/mainboard/romstage.c"}; err != nil || !reflect.DeepEqual(files, want) {
+			t.Errorf("got %v, %v want %v", files, err, want)
+		}
+	})
+	t.Run("file content", func(t *testing.T) {
+		content, err := GiteaAPI{}.GetFileContent(ctx, "firmware", "coreboot", ".contest/build.yaml", giteaTestSHA)
+		if err != nil || string(content) != "JobName: Build Test" {
+			t.Errorf("got %q, %v want %q", content, err, "JobName: Build Test")
+		}
+		if _, err := (GiteaAPI{}).GetFileContent(ctx, "firmware", "coreboot", ".contest/missing.yaml", giteaTestSHA); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v want %v", err, ErrNotFound)
+		}
+	})
+}
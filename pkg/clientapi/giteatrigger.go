@@ -0,0 +1,243 @@
+package clientapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// maxGiteaPayload limits the size of the Gitea webhook payloads
+const maxGiteaPayload = 25 << 20
+
+// giteaUser is a user in the Gitea webhook payloads
+type giteaUser struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+}
+
+// giteaRepository is a repository in the Gitea webhook payloads
+type giteaRepository struct {
+	Name     string    `json:"name"`
+	FullName string    `json:"full_name"`
+	Owner    giteaUser `json:"owner"`
+	HTMLURL  string    `json:"html_url"`
+	SSHURL   string    `json:"ssh_url"`
+	CloneURL string    `json:"clone_url"`
+}
+
+// giteaPushEvent is the payload of the Gitea push webhook
+type giteaPushEvent struct {
+	Ref     string `json:"ref"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Commits []struct {
+		ID       string   `json:"id"`
+		Message  string   `json:"message"`
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+	Repository giteaRepository `json:"repository"`
+	Pusher     giteaUser       `json:"pusher"`
+	Sender     giteaUser       `json:"sender"`
+}
+
+// giteaBranch is the head or base of a Gitea pull request
+type giteaBranch struct {
+	Ref  string          `json:"ref"`
+	SHA  string          `json:"sha"`
+	Repo giteaRepository `json:"repo"`
+}
+
+// giteaPullRequestEvent is the payload of the Gitea pull_request webhook
+type giteaPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Number int         `json:"number"`
+		Title  string      `json:"title"`
+		User   giteaUser   `json:"user"`
+		Head   giteaBranch `json:"head"`
+		Base   giteaBranch `json:"base"`
+	} `json:"pull_request"`
+	Repository giteaRepository `json:"repository"`
+}
+
+// GiteaTrigger receives the push and pull request webhooks of Gitea and Forgejo. The instance is selected by the
+// repository of the payload, the X-Gitea-Signature header has to be the HMAC-SHA256 of the payload with the
+// secret of the instance.
+type GiteaTrigger struct {
+	Instances []client.Gitea
+}
+
+// giteaEventType returns the event type of a Gitea or Forgejo webhook, empty for webhooks of other forges
+func giteaEventType(r *http.Request) string {
+	if event := r.Header.Get("X-Forgejo-Event"); event != "" {
+		return event
+	}
+	return r.Header.Get("X-Gitea-Event")
+}
+
+// Match returns true for webhooks with the event header of Gitea or Forgejo. They also send the
+// event header of GitHub, the trigger has to be checked before the GitHub trigger.
+func (g GiteaTrigger) Match(r *http.Request) bool {
+	return giteaEventType(r) != ""
+}
+
+// Parse validates the signature of the webhook and returns the event of pushes and opened or reopened pull requests
+func (g GiteaTrigger) Parse(r *http.Request) (*Event, error) {
+	payload, err := readPayload(r, maxGiteaPayload)
+	if err != nil {
+		return nil, err
+	}
+	eventType := giteaEventType(r)
+
+	var event struct {
+		Repository giteaRepository `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "could not parse webhook", Err: err}
+	}
+	instance, found := client.GiteaFor(g.Instances, event.Repository.FullName, event.Repository.HTMLURL)
+	if !found {
+		return nil, &RequestError{Code: http.StatusForbidden, Msg: "unknown repository",
+			Err: fmt.Errorf("no gitea instance is configured for %s", event.Repository.FullName)}
+	}
+	signature := r.Header.Get("X-Forgejo-Signature")
+	if signature == "" {
+		signature = r.Header.Get("X-Gitea-Signature")
+	}
+	if !validGiteaSignature(payload, signature, instance.Secret()) {
+		return nil, &RequestError{Code: http.StatusUnauthorized, Msg: "invalid webhook signature",
+			Err: fmt.Errorf("invalid X-Gitea-Signature of the %s webhook for %s", eventType, event.Repository.FullName)}
+	}
+
+	e, err := parseGiteaWebhook(eventType, payload)
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "could not parse webhook", Err: err}
+	}
+	if e != nil && e.DeliveryID == "" {
+		e.DeliveryID = r.Header.Get("X-Forgejo-Delivery")
+		if e.DeliveryID == "" {
+			e.DeliveryID = r.Header.Get("X-Gitea-Delivery")
+		}
+	}
+	return e, nil
+}
+
+// validGiteaSignature returns true if the signature is the hex encoded HMAC-SHA256 of the payload. Webhooks
+// are never valid without a secret.
+func validGiteaSignature(payload []byte, signature string, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// parseGiteaWebhook maps the push and pull request webhooks of Gitea to events.
+// Other events and pull request actions that do not start jobs return no event.
+func parseGiteaWebhook(eventType string, payload []byte) (*Event, error) {
+	switch eventType {
+	case "push":
+		var e giteaPushEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		// Deleted branches have no commit to test
+		if e.After == "" || e.After == "0000000000000000000000000000000000000000" {
+			return nil, nil
+		}
+		event := giteaPushData(e)
+		event.DeliveryID = CommitDeliveryID(event.Forge, e.Repository.FullName, event.Branch, event.SHA)
+		return event, nil
+	case "pull_request":
+		var e giteaPullRequestEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		if e.Action != "opened" && e.Action != "reopened" && e.Action != "synchronized" {
+			return nil, nil
+		}
+		event := giteaPullRequestData(e)
+		// New commits of pull requests of the same repository are also received as push
+		if e.Action == "synchronized" && event.SSHURL == event.BaseSSHURL {
+			event.DeliveryID = CommitDeliveryID(event.Forge, e.Repository.FullName, event.Branch, event.SHA)
+		}
+		return event, nil
+	}
+	log.Printf("successful received unknown gitea event %s\n", eventType)
+	return nil, nil
+}
+
+// giteaPushData returns the commit SHA, the repository, the branch and the changed files of the push
+func giteaPushData(e giteaPushEvent) *Event {
+	owner, name := splitFullName(e.Repository.FullName)
+	branch := strings.TrimPrefix(e.Ref, "refs/heads/")
+	event := &Event{
+		Forge:        ForgeGitea,
+		Type:         "push",
+		SHA:          e.After,
+		BaseSHA:      e.Before,
+		SSHURL:       e.Repository.SSHURL,
+		CloneURL:     e.Repository.CloneURL,
+		BaseSSHURL:   e.Repository.SSHURL,
+		BaseCloneURL: e.Repository.CloneURL,
+		Branch:       branch,
+		Ref:          e.Ref,
+		BaseBranch:   branch,
+		RepoOwner:    owner,
+		RepoName:     name,
+		Author:       e.Sender.Login,
+	}
+	if event.Author == "" {
+		event.Author = e.Pusher.Login
+	}
+
+	var commitFiles [][]string
+	for _, commit := range e.Commits {
+		if commit.ID == e.After {
+			event.Title = firstLine(commit.Message)
+		}
+		commitFiles = append(commitFiles, commit.Added, commit.Removed, commit.Modified)
+	}
+	event.ChangedFiles = pushedFiles(commitFiles...)
+	return event
+}
+
+// giteaPullRequestData returns the head and base of the pull request and the repository.
+// The changed files of the pull request are not part of the payload, they are requested by the worker
+func giteaPullRequestData(e giteaPullRequestEvent) *Event {
+	pr := e.PullRequest
+	owner, name := splitFullName(e.Repository.FullName)
+	return &Event{
+		Forge:        ForgeGitea,
+		Type:         "pull_request",
+		SHA:          pr.Head.SHA,
+		BaseSHA:      pr.Base.SHA,
+		SSHURL:       pr.Head.Repo.SSHURL,
+		CloneURL:     pr.Head.Repo.CloneURL,
+		BaseSSHURL:   pr.Base.Repo.SSHURL,
+		BaseCloneURL: pr.Base.Repo.CloneURL,
+		Branch:       pr.Head.Ref,
+		Ref:          fmt.Sprintf("refs/pull/%d/head", pr.Number),
+		BaseBranch:   pr.Base.Ref,
+		RepoOwner:    owner,
+		RepoName:     name,
+		PRNumber:     pr.Number,
+		Title:        pr.Title,
+		Author:       pr.User.Login,
+	}
+}
@@ -2,31 +2,25 @@ package clientapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
+// ErrNotFound is returned if the requested file does not exist in the repository
+var ErrNotFound = errors.New("not found")
+
 type GithubAPI struct {
 }
 
-func (g GithubAPI) EditGithubStatus(ctx context.Context, state string, targeturl string, description string, sha string) error {
-	// Getting env variable GH_TOKEN
-	githubToken := os.Getenv("GITHUB_TOKEN")
-
-	// Setting up the github authentication
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: githubToken},
-	)
-	// Setting up a github client
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
-	if client == nil {
-		return fmt.Errorf("the github client has not set up")
+func (g GithubAPI) EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error {
+	// The repository the status belongs to has to be known
+	if owner == "" || repo == "" {
+		return fmt.Errorf("the repository owner and name have to be set")
 	}
 
 	// If the state is different to the possible github states, error
@@ -34,21 +28,158 @@ func (g GithubAPI) EditGithubStatus(ctx context.Context, state string, targeturl
 		return fmt.Errorf("state has no correct value")
 	}
 	// If the targetURL is not empty and wrong formatted, return
-	_, err := url.ParseRequestURI(targeturl)
-	if err != nil {
-		return fmt.Errorf("TargetURL of the results is not formatted right! GithubStatus could not be edited")
+	if targeturl != "" {
+		if _, err := url.ParseRequestURI(targeturl); err != nil {
+			return fmt.Errorf("TargetURL of the results is not formatted right! GithubStatus could not be edited")
+		}
 	}
 	// Check if sha is a correct formatted sha1 hash else return
 	match, err := regexp.MatchString("[a-f0-9]{40}", sha)
 	if !match {
 		return fmt.Errorf("the commit sha was not handed over correctly: %w", err)
 	}
+	// Getting the github client for the repository
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("the github client has not set up: %w", err)
+	}
 	// Putting the CreateStatus input together and change the status of the commit
-	input := &github.RepoStatus{State: &state, TargetURL: &targeturl, Context: &description}
+	input := &github.RepoStatus{State: &state, Context: &statusContext}
+	if targeturl != "" {
+		input.TargetURL = &targeturl
+	}
+	if description != "" {
+		input.Description = &description
+	}
+
+	_, _, err = client.Repositories.CreateStatus(ctx, owner, repo, sha, input)
+	if err != nil {
+		return fmt.Errorf("could not set status of the commit in %s/%s to %s, err: %s", owner, repo, state, err)
+	}
+	return nil
+}
+
+// ReportStatus sets the commit status, GitHub has no running state, running jobs are pending
+func (g GithubAPI) ReportStatus(ctx context.Context, status Status) error {
+	return g.EditGithubStatus(ctx, status.Owner, status.Repo, githubState(status.State), status.TargetURL, status.Context,
+		status.Description, status.SHA)
+}
 
-	_, _, err = client.Repositories.CreateStatus(ctx, "9elements", "coreboot-spr-sp", sha, input)
+// githubState returns the commit state of GitHub for the state of the job
+func githubState(state State) string {
+	if state == StateRunning {
+		return string(StatePending)
+	}
+	return string(state)
+}
+
+// ListPullRequestFiles returns the names of the files that are changed by the pull request
+func (g GithubAPI) ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, sha string) ([]string, error) {
+	return g.ListChangedFiles(ctx, owner, repo, number)
+}
+
+// ListChangedFiles returns the names of the files that are changed by the pull request
+func (g GithubAPI) ListChangedFiles(ctx context.Context, owner string, repo string, number int) ([]string, error) {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("the github client has not set up: %w", err)
+	}
+	var files []string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		commitFiles, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("could not list the changed files of the pull request %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, file := range commitFiles {
+			files = append(files, file.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// GetFileContent returns the content of the file in the repository at the given ref, e.g. a commit SHA.
+// If the file does not exist, ErrNotFound is returned.
+func (g GithubAPI) GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error) {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("the github client has not set up: %w", err)
+	}
+	file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s in %s/%s at %s: %w", path, owner, repo, ref, ErrNotFound)
+	}
 	if err != nil {
-		return fmt.Errorf("could not set status of the commit to %s, err: %s", state, err)
+		return nil, fmt.Errorf("could not get %s from %s/%s at %s: %w", path, owner, repo, ref, err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s in %s/%s at %s is not a file", path, owner, repo, ref)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s from %s/%s at %s: %w", path, owner, repo, ref, err)
+	}
+	return []byte(content), nil
+}
+
+// HasWriteAccess returns true if the user has the write or admin permission on the repository,
+// read-only collaborators are not accepted
+func (g GithubAPI) HasWriteAccess(ctx context.Context, owner string, repo string, user string) (bool, error) {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return false, fmt.Errorf("the github client has not set up: %w", err)
+	}
+	level, _, err := client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, fmt.Errorf("could not retrieve the permission of %s on %s/%s: %w", user, owner, repo, err)
+	}
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+	return false, nil
+}
+
+// PullRequest returns the event of the current head commit of the pull request
+func (g GithubAPI) PullRequest(ctx context.Context, owner string, repo string, number int) (*Event, error) {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("the github client has not set up: %w", err)
+	}
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the pull request %s/%s#%d: %w", owner, repo, number, err)
+	}
+	return githubPullRequest(pr, owner+"/"+repo), nil
+}
+
+// Acknowledge reacts with +1 to the comment of an accepted command and with confused to a rejected one.
+// The reply is posted as a comment on the pull request.
+func (g GithubAPI) Acknowledge(ctx context.Context, owner string, repo string, number int, command Command, accepted bool, reply string) error {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("the github client has not set up: %w", err)
+	}
+	reaction := "+1"
+	if !accepted {
+		reaction = "confused"
+	}
+	if command.ReviewComment {
+		_, _, err = client.Reactions.CreatePullRequestCommentReaction(ctx, owner, repo, command.CommentID, reaction)
+	} else {
+		_, _, err = client.Reactions.CreateIssueCommentReaction(ctx, owner, repo, command.CommentID, reaction)
+	}
+	if err != nil {
+		return fmt.Errorf("could not react to the comment %d in %s/%s: %w", command.CommentID, owner, repo, err)
+	}
+	if reply == "" {
+		return nil
+	}
+	if _, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &reply}); err != nil {
+		return fmt.Errorf("could not reply to the command in %s/%s#%d: %w", owner, repo, number, err)
 	}
 	return nil
 }
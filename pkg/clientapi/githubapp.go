@@ -0,0 +1,236 @@
+package clientapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// githubAPIURL is the base URL of the GitHub REST API
+	githubAPIURL = "https://api.github.com"
+	// tokenRefreshMargin defines how long before their expiry the installation tokens are refreshed
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// githubClients builds the github clients once and reuses them for all requests. Without a
+// GitHub App a single client is authenticated with the GITHUB_TOKEN env variable, with a
+// GitHub App there is one client per installation.
+type githubClients struct {
+	lock sync.Mutex
+
+	app           *githubApp
+	tokenClient   *github.Client
+	repositories  map[string]int64 // Installation ID per "owner/repo"
+	installations map[int64]*github.Client
+}
+
+var clients = &githubClients{
+	repositories:  make(map[string]int64),
+	installations: make(map[int64]*github.Client),
+}
+
+// SetupGithubApp configures the github clients to authenticate as the GitHub App with the
+// given ID. The private key of the app is read from the PEM file.
+func SetupGithubApp(appID int64, privateKeyFile string) error {
+	keyData, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		return fmt.Errorf("could not read the private key of the GitHub App: %w", err)
+	}
+	privateKey, err := parsePrivateKey(keyData)
+	if err != nil {
+		return err
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	clients.app = &githubApp{
+		id:         appID,
+		privateKey: privateKey,
+		baseURL:    githubAPIURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	clients.repositories = make(map[string]int64)
+	clients.installations = make(map[int64]*github.Client)
+	return nil
+}
+
+// githubClient returns the github client that is allowed to access the repository
+func githubClient(ctx context.Context, owner string, repo string) (*github.Client, error) {
+	clients.lock.Lock()
+	// Without a GitHub App every repository is accessed with the GITHUB_TOKEN
+	if clients.app == nil {
+		if clients.tokenClient == nil {
+			ts := oauth2.StaticTokenSource(
+				&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
+			)
+			clients.tokenClient = github.NewClient(oauth2.NewClient(context.Background(), ts))
+		}
+		client := clients.tokenClient
+		clients.lock.Unlock()
+		return client, nil
+	}
+	app := clients.app
+	fullName := owner + "/" + repo
+	installationID, found := clients.repositories[fullName]
+	clients.lock.Unlock()
+
+	// Look up the installation of the app for the repository, the other repositories are not blocked by the request
+	if !found {
+		var err error
+		installationID, err = app.installationID(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	// The installation token is cached by the token source and refreshed before it expires
+	client, found := clients.installations[installationID]
+	if !found {
+		ts := oauth2.ReuseTokenSource(nil, &installationTokenSource{app: app, installationID: installationID})
+		client = github.NewClient(oauth2.NewClient(context.Background(), ts))
+	}
+	// The clients of an app that was replaced by SetupGithubApp during the request are not cached
+	if clients.app == app {
+		clients.repositories[fullName] = installationID
+		clients.installations[installationID] = client
+	}
+	return client, nil
+}
+
+// githubApp authenticates as a GitHub App to request installation tokens
+type githubApp struct {
+	id         int64
+	privateKey *rsa.PrivateKey
+	baseURL    string
+	httpClient *http.Client
+}
+
+// jwt creates the JSON Web Token that authenticates the app, it is valid for ten minutes
+func (a *githubApp) jwt(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(), // Allow some clock drift to GitHub
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.id,
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("could not sign the JWT of the GitHub App: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// installationID returns the ID of the app installation that has access to the repository
+func (a *githubApp) installationID(ctx context.Context, owner string, repo string) (int64, error) {
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err := a.request(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/installation", owner, repo), &installation); err != nil {
+		return 0, fmt.Errorf("could not find the GitHub App installation for %s/%s: %w", owner, repo, err)
+	}
+	return installation.ID, nil
+}
+
+// installationToken requests a new access token for the installation
+func (a *githubApp) installationToken(ctx context.Context, installationID int64) (*oauth2.Token, error) {
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := a.request(ctx, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", installationID), &token); err != nil {
+		return nil, fmt.Errorf("could not create an access token for the GitHub App installation %d: %w", installationID, err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.Token,
+		TokenType:   "token",
+		// Refresh the token a bit before it actually expires
+		Expiry: token.ExpiresAt.Add(-tokenRefreshMargin),
+	}, nil
+}
+
+// request sends a request that is authenticated as the app and decodes the JSON response into v
+func (a *githubApp) request(ctx context.Context, method string, path string, v interface{}) error {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(a.baseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// installationTokenSource is an oauth2.TokenSource that requests installation tokens of the app
+type installationTokenSource struct {
+	app            *githubApp
+	installationID int64
+}
+
+// Token requests a new installation token, it is cached by oauth2.ReuseTokenSource until it expires
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	return s.app.installationToken(context.Background(), s.installationID)
+}
+
+// parsePrivateKey decodes the PEM encoded PKCS#1 or PKCS#8 RSA private key of the app
+func parsePrivateKey(keyData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, fmt.Errorf("the private key of the GitHub App is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the private key of the GitHub App: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of the GitHub App is not an RSA key")
+	}
+	return rsaKey, nil
+}
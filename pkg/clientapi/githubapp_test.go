@@ -0,0 +1,140 @@
+package clientapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Test for githubApp, if the installation of a repository is found and a token is requested with a valid JWT
+func TestGithubApp(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate the private key: %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	lookup, release := make(chan struct{}), make(chan struct{})
+
+	// Fake GitHub API that checks the JWT of the app
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifyJWT(r.Header.Get("Authorization"), &privateKey.PublicKey, 1234); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/9elements/coreboot-spr-sp/installation":
+			fmt.Fprint(w, `{"id": 42}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/9elements/slow/installation":
+			lookup <- struct{}{}
+			<-release
+			fmt.Fprint(w, `{"id": 43}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens":
+			fmt.Fprintf(w, `{"token": "ghs_test", "expires_at": "%s"}`, expiresAt.Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	app := &githubApp{id: 1234, privateKey: privateKey, baseURL: server.URL, httpClient: server.Client()}
+
+	installationID, err := app.installationID(context.Background(), "9elements", "coreboot-spr-sp")
+	if err != nil {
+		t.Fatalf("function 'installationID' returned an error: %v", err)
+	}
+	if installationID != 42 {
+		t.Errorf("got installation %d want 42", installationID)
+	}
+
+	token, err := (&installationTokenSource{app: app, installationID: installationID}).Token()
+	if err != nil {
+		t.Fatalf("function 'Token' returned an error: %v", err)
+	}
+	if token.AccessToken != "ghs_test" {
+		t.Errorf("got token %q want %q", token.AccessToken, "ghs_test")
+	}
+	// The token has to be refreshed before GitHub expires it
+	if want := expiresAt.Add(-tokenRefreshMargin); !token.Expiry.Equal(want) {
+		t.Errorf("got expiry %v want %v", token.Expiry, want)
+	}
+
+	if _, err := app.installationID(context.Background(), "9elements", "unknown"); err == nil {
+		t.Errorf("function 'installationID' returned no error for an unknown repository")
+	}
+
+	// A slow lookup of an installation does not block the clients of the repositories that are known
+	clients.lock.Lock()
+	clients.app, clients.repositories, clients.installations = app, map[string]int64{"9elements/coreboot-spr-sp": 42}, make(map[int64]*github.Client)
+	clients.lock.Unlock()
+	defer func() {
+		clients.lock.Lock()
+		clients.app, clients.repositories, clients.installations = nil, make(map[string]int64), make(map[int64]*github.Client)
+		clients.lock.Unlock()
+	}()
+	slow := make(chan error, 1)
+	go func() {
+		_, err := githubClient(context.Background(), "9elements", "slow")
+		slow <- err
+	}()
+	<-lookup
+	known := make(chan error, 1)
+	go func() {
+		_, err := githubClient(context.Background(), "9elements", "coreboot-spr-sp")
+		known <- err
+	}()
+	select {
+	case err := <-known:
+		if err != nil {
+			t.Errorf("function 'githubClient' returned an error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the client of a known repository waited for the lookup of another installation")
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("function 'githubClient' returned an error: %v", err)
+	}
+}
+
+// verifyJWT checks the signature and the issuer of the JWT in the authorization header
+func verifyJWT(authorization string, publicKey *rsa.PublicKey, appID int64) error {
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature); err != nil {
+		return err
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		Iss int64 `json:"iss"`
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return err
+	}
+	if claims.Iss != appID || claims.Exp < time.Now().Unix() {
+		return fmt.Errorf("invalid claims %+v", claims)
+	}
+	return nil
+}
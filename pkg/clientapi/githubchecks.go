@@ -0,0 +1,115 @@
+package clientapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// GithubChecks reports the jobs as check runs with the GitHub Checks API
+type GithubChecks interface {
+	CreateCheckRun(ctx context.Context, owner string, repo string, name string, sha string, detailsURL string) (int64, error)
+	UpdateCheckRun(ctx context.Context, owner string, repo string, checkRunID int64, update CheckRunUpdate) error
+}
+
+// CheckRunUpdate contains the fields of a check run that can be updated
+type CheckRunUpdate struct {
+	Status     string          `json:"status,omitempty"`     // queued, in_progress or completed
+	Conclusion string          `json:"conclusion,omitempty"` // success, failure, neutral or cancelled, only if completed
+	Output     *CheckRunOutput `json:"output,omitempty"`
+}
+
+// CheckRunOutput is the Markdown report that is shown in the check run
+type CheckRunOutput struct {
+	Title       string               `json:"title"`
+	Summary     string               `json:"summary"`
+	Text        string               `json:"text,omitempty"`
+	Annotations []CheckRunAnnotation `json:"annotations,omitempty"`
+}
+
+// CheckRunAnnotation marks a problem that is shown next to the check run
+type CheckRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"` // notice, warning or failure
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+	RawDetails      string `json:"raw_details,omitempty"`
+}
+
+// MaxCheckRunAnnotations is the maximum number of annotations GitHub accepts per request
+const MaxCheckRunAnnotations = 50
+
+// checkRun is the part of the check run object that is sent to and returned by GitHub.
+// The check runs are requested directly, since the structs of the vendored go-github
+// version do not match the current Checks API.
+type checkRun struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	DetailsURL  string          `json:"details_url,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+}
+
+type GithubChecksAPI struct {
+}
+
+// CreateCheckRun creates a check run with the status in_progress for the commit and returns its ID
+func (g GithubChecksAPI) CreateCheckRun(ctx context.Context, owner string, repo string, name string, sha string,
+	detailsURL string) (int64, error) {
+	if owner == "" || repo == "" {
+		return 0, fmt.Errorf("the repository owner and name have to be set")
+	}
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("the github client has not set up: %w", err)
+	}
+
+	now := time.Now()
+	input := checkRun{Name: name, HeadSHA: sha, DetailsURL: detailsURL, Status: "in_progress", StartedAt: &now}
+	req, err := client.NewRequest(http.MethodPost, fmt.Sprintf("repos/%s/%s/check-runs", owner, repo), input)
+	if err != nil {
+		return 0, fmt.Errorf("could not create the check run request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	var created checkRun
+	if _, err := client.Do(ctx, req, &created); err != nil {
+		return 0, fmt.Errorf("could not create the check run for %s in %s/%s: %w", sha, owner, repo, err)
+	}
+	return created.ID, nil
+}
+
+// UpdateCheckRun updates the status, the conclusion and the report of the check run
+func (g GithubChecksAPI) UpdateCheckRun(ctx context.Context, owner string, repo string, checkRunID int64,
+	update CheckRunUpdate) error {
+	client, err := githubClient(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("the github client has not set up: %w", err)
+	}
+	if update.Output != nil && len(update.Output.Annotations) > MaxCheckRunAnnotations {
+		return fmt.Errorf("GitHub accepts at most %d annotations per request", MaxCheckRunAnnotations)
+	}
+
+	input := checkRun{Status: update.Status, Conclusion: update.Conclusion, Output: update.Output}
+	if update.Status == "completed" {
+		now := time.Now()
+		input.CompletedAt = &now
+	}
+	req, err := client.NewRequest(http.MethodPatch, fmt.Sprintf("repos/%s/%s/check-runs/%d", owner, repo, checkRunID), input)
+	if err != nil {
+		return fmt.Errorf("could not create the check run request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	if _, err := client.Do(ctx, req, nil); err != nil {
+		return fmt.Errorf("could not update the check run %d in %s/%s: %w", checkRunID, owner, repo, err)
+	}
+	return nil
+}
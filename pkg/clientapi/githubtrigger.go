@@ -0,0 +1,193 @@
+package clientapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GithubTrigger receives the push and pull request webhooks of GitHub. The webhooks are validated
+// with the secret in the GITHUB_SECRET env variable.
+type GithubTrigger struct {
+}
+
+// Match returns true for webhooks with the event header of GitHub
+func (g GithubTrigger) Match(r *http.Request) bool {
+	return r.Header.Get("X-GitHub-Event") != ""
+}
+
+// Parse validates the webhook and returns the event of pushes, opened or reopened pull requests and
+// comments on pull requests with slash commands. New commits of pull requests of the same repository
+// are tested once, by the first of the push and the synchronize webhook.
+func (g GithubTrigger) Parse(r *http.Request) (*Event, error) {
+	//retrieve the github_secret for the webhook from .env
+	github_secret := os.Getenv("GITHUB_SECRET")
+	// Receiving and validating the incoming webhook
+	payload, err := github.ValidatePayload(r, []byte(github_secret))
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "invalid webhook payload", Err: err}
+	}
+	defer r.Body.Close()
+
+	// Parsing the incoming webhook
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "could not parse webhook", Err: err}
+	}
+
+	// Switch to handle the different eventtypes of the webhook
+	var e *Event
+	switch event := event.(type) {
+	case *github.PullRequestEvent:
+		action := event.GetAction()
+		if action != "opened" && action != "reopened" && action != "synchronize" {
+			return nil, nil
+		}
+		fmt.Printf("successful received pullrequest event\n")
+		e = GithubPullRequestEvent(event)
+		if action == "synchronize" && e.SSHURL == e.BaseSSHURL {
+			e.DeliveryID = CommitDeliveryID(e.Forge, e.RepoOwner+"/"+e.RepoName, e.Branch, e.SHA)
+			return e, nil
+		}
+	case *github.PushEvent:
+		// Deleted branches have no commit to test
+		if event.GetDeleted() || event.GetAfter() == "" || event.GetAfter() == "0000000000000000000000000000000000000000" {
+			return nil, nil
+		}
+		fmt.Printf("successful received push event\n")
+		e = GithubPushEvent(event)
+		e.DeliveryID = CommitDeliveryID(e.Forge, e.RepoOwner+"/"+e.RepoName, e.Branch, e.SHA)
+		return e, nil
+	case *github.IssueCommentEvent:
+		// Only new comments on pull requests can contain commands
+		if event.GetAction() != "created" || !event.GetIssue().IsPullRequest() {
+			return nil, nil
+		}
+		command, found := ParseCommand(event.GetComment().GetBody())
+		if !found {
+			return nil, nil
+		}
+		command.User = event.GetComment().GetUser().GetLogin()
+		command.CommentID = event.GetComment().GetID()
+		// The head of the pull request is not part of the payload, it is requested by the worker
+		owner, name := splitFullName(event.GetRepo().GetFullName())
+		e = &Event{Forge: ForgeGithub, Type: "pull_request", RepoOwner: owner, RepoName: name,
+			PRNumber: event.GetIssue().GetNumber(), Command: &command}
+	case *github.PullRequestReviewCommentEvent:
+		if event.GetAction() != "created" {
+			return nil, nil
+		}
+		command, found := ParseCommand(event.GetComment().GetBody())
+		if !found {
+			return nil, nil
+		}
+		command.User = event.GetComment().GetUser().GetLogin()
+		command.CommentID = event.GetComment().GetID()
+		command.ReviewComment = true
+		e = githubPullRequest(event.GetPullRequest(), event.GetRepo().GetFullName())
+		e.Command = &command
+	default:
+		log.Printf("successful received unknown event %s %s\n", github.WebHookType(r), event)
+		return nil, nil
+	}
+	e.DeliveryID = github.DeliveryID(r)
+	return e, nil
+}
+
+// GithubPullRequestEvent returns the head and base of the pull request and the repository.
+// The changed files of the pull request are not part of the payload, they are requested by the worker
+func GithubPullRequestEvent(e *github.PullRequestEvent) *Event {
+	return githubPullRequest(e.GetPullRequest(), e.GetRepo().GetFullName())
+}
+
+// githubPullRequest returns the event of the pull request of the repository "owner/name"
+func githubPullRequest(pr *github.PullRequest, fullName string) *Event {
+	owner, name := splitFullName(fullName)
+	return &Event{
+		Forge:        ForgeGithub,
+		Type:         "pull_request",
+		SHA:          pr.GetHead().GetSHA(),
+		BaseSHA:      pr.GetBase().GetSHA(),
+		SSHURL:       pr.GetHead().GetRepo().GetSSHURL(),
+		CloneURL:     pr.GetHead().GetRepo().GetCloneURL(),
+		BaseSSHURL:   pr.GetBase().GetRepo().GetSSHURL(),
+		BaseCloneURL: pr.GetBase().GetRepo().GetCloneURL(),
+		Branch:       pr.GetHead().GetRef(),
+		Ref:          fmt.Sprintf("refs/pull/%d/head", pr.GetNumber()),
+		BaseBranch:   pr.GetBase().GetRef(),
+		RepoOwner:    owner,
+		RepoName:     name,
+		PRNumber:     pr.GetNumber(),
+		Title:        pr.GetTitle(),
+		Author:       pr.GetUser().GetLogin(),
+	}
+}
+
+// GithubPushEvent returns the commit SHA, the repository, the branch and the changed files of the push
+func GithubPushEvent(e *github.PushEvent) *Event {
+	owner, name := splitFullName(e.GetRepo().GetFullName())
+	branch := strings.TrimPrefix(e.GetRef(), "refs/heads/")
+	return &Event{
+		Forge:        ForgeGithub,
+		Type:         "push",
+		SHA:          e.GetAfter(),
+		BaseSHA:      e.GetBefore(),
+		SSHURL:       e.GetRepo().GetSSHURL(),
+		CloneURL:     e.GetRepo().GetCloneURL(),
+		BaseSSHURL:   e.GetRepo().GetSSHURL(),
+		BaseCloneURL: e.GetRepo().GetCloneURL(),
+		Branch:       branch,
+		Ref:          e.GetRef(),
+		BaseBranch:   branch,
+		RepoOwner:    owner,
+		RepoName:     name,
+		Title:        firstLine(e.GetHeadCommit().GetMessage()),
+		Author:       e.GetSender().GetLogin(),
+		ChangedFiles: githubPushedFiles(e.Commits),
+	}
+}
+
+// firstLine returns the first line of a commit message, the subject
+func firstLine(message string) string {
+	return strings.SplitN(message, "\n", 2)[0]
+}
+
+// githubPushedFiles returns the sorted list of files that were added, removed or modified by the pushed commits
+func githubPushedFiles(commits []github.PushEventCommit) []string {
+	var commitFiles [][]string
+	for _, commit := range commits {
+		commitFiles = append(commitFiles, commit.Added, commit.Removed, commit.Modified)
+	}
+	return pushedFiles(commitFiles...)
+}
+
+// pushedFiles returns the sorted list of files that were added, removed or modified by the pushed commits,
+// the added, removed and modified files of every commit are passed as separate lists
+func pushedFiles(commitFiles ...[]string) []string {
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, list := range commitFiles {
+		for _, file := range list {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// splitFullName splits the full name "owner/name" of a repository into owner and name
+func splitFullName(fullName string) (string, string) {
+	parts := strings.SplitN(fullName, "/", 2)
+	if len(parts) != 2 {
+		return "", fullName
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,129 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// gitlabURL is the default base URL of GitLab
+const gitlabURL = "https://gitlab.com"
+
+// gitlabStates maps the commit states of GitHub to the pipeline states of GitLab
+var gitlabStates = map[string]string{
+	"pending": "running",
+	"success": "success",
+	"failure": "failed",
+	"error":   "failed",
+}
+
+// shaPattern matches a full commit SHA
+var shaPattern = regexp.MustCompile("^[a-f0-9]{40}$")
+
+// gitlab is the configured GitLab instance
+var gitlab = struct {
+	lock    sync.Mutex
+	baseURL string
+}{baseURL: gitlabURL}
+
+// SetupGitlab configures the base URL of the self-hosted GitLab instance, e.g. https://gitlab.example.com
+func SetupGitlab(baseURL string) {
+	gitlab.lock.Lock()
+	defer gitlab.lock.Unlock()
+	gitlab.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GitlabAPI posts commit statuses to GitLab. It implements the Github interface, the states of GitHub are
+// mapped to the pipeline states of GitLab. The token is read from the GITLAB_TOKEN env variable.
+type GitlabAPI struct {
+	BaseURL    string       // Base URL of GitLab, the configured instance is used if it is empty
+	Token      string       // Access token with the api scope, GITLAB_TOKEN is used if it is empty
+	HTTPClient *http.Client // HTTP client of the requests, a client with a timeout of 30s is used if it is nil
+}
+
+// EditGithubStatus sets the commit status of the GitLab project "owner/repo", the owner may contain subgroups
+func (g GitlabAPI) EditGithubStatus(ctx context.Context, owner string, repo string, state string, targeturl string, statusContext string, description string, sha string) error {
+	gitlabState, found := gitlabStates[state]
+	if !found {
+		return fmt.Errorf("state has no correct value")
+	}
+	return g.setStatus(ctx, owner, repo, gitlabState, targeturl, statusContext, description, sha)
+}
+
+// ReportStatus sets the commit status, GitLab distinguishes pending and running jobs
+func (g GitlabAPI) ReportStatus(ctx context.Context, status Status) error {
+	if status.State == StatePending {
+		return g.setStatus(ctx, status.Owner, status.Repo, "pending", status.TargetURL, status.Context, status.Description, status.SHA)
+	}
+	return g.EditGithubStatus(ctx, status.Owner, status.Repo, githubState(status.State), status.TargetURL, status.Context,
+		status.Description, status.SHA)
+}
+
+// setStatus sets the pipeline state of the commit in the GitLab project
+func (g GitlabAPI) setStatus(ctx context.Context, owner string, repo string, gitlabState string, targeturl string, statusContext string, description string, sha string) error {
+	// The project the status belongs to has to be known
+	if owner == "" || repo == "" {
+		return fmt.Errorf("the project owner and name have to be set")
+	}
+	if targeturl != "" {
+		if _, err := url.ParseRequestURI(targeturl); err != nil {
+			return fmt.Errorf("TargetURL of the results is not formatted right! GitlabStatus could not be edited")
+		}
+	}
+	if !shaPattern.MatchString(sha) {
+		return fmt.Errorf("the SHA is not a correct formatted sha1 hash")
+	}
+
+	status := struct {
+		State       string `json:"state"`
+		Name        string `json:"name,omitempty"`
+		TargetURL   string `json:"target_url,omitempty"`
+		Description string `json:"description,omitempty"`
+	}{gitlabState, statusContext, targeturl, description}
+	path := fmt.Sprintf("/api/v4/projects/%s/statuses/%s", url.PathEscape(owner+"/"+repo), sha)
+	if err := g.request(ctx, http.MethodPost, path, status, nil); err != nil {
+		return fmt.Errorf("could not set the gitlab status of %s/%s: %w", owner, repo, err)
+	}
+	return nil
+}
+
+// request sends the JSON body to the GitLab API and decodes the JSON response into v, if it is not nil
+func (g GitlabAPI) request(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		gitlab.lock.Lock()
+		baseURL = gitlab.baseURL
+		gitlab.lock.Unlock()
+	}
+	token := g.Token
+	if token == "" {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+	api := restAPI{baseURL: baseURL, httpClient: g.HTTPClient, authorize: func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}}
+	return api.request(ctx, method, path, body, v)
+}
+
+// GetFileContent returns the content of the file in the GitLab project at the given ref, e.g. a commit SHA.
+// If the file does not exist, ErrNotFound is returned.
+func (g GitlabAPI) GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error) {
+	var content []byte
+	apiPath := fmt.Sprintf("/api/v4/projects/%s/repository/files/%s/raw?ref=%s", url.PathEscape(owner+"/"+repo),
+		url.PathEscape(path), url.QueryEscape(ref))
+	err := g.request(ctx, http.MethodGet, apiPath, nil, &rawBody{&content})
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return nil, fmt.Errorf("%s in %s/%s at %s: %w", path, owner, repo, ref, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s from %s/%s at %s: %w", path, owner, repo, ref, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,79 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const gitlabTestSHA = "a94a8fe5ccb19ba61c4c0873daa391e9872fbbd3"
+
+// Test for GitlabAPI, if the commit statuses are posted as pipeline states to a fake GitLab
+func TestGitlabAPI(t *testing.T) {
+	var got map[string]string
+	server := newFakeForge(func(r *http.Request) bool {
+		return r.Header.Get("PRIVATE-TOKEN") == "glpat-test"
+	}, map[string]http.HandlerFunc{
+		"POST /api/v4/projects/firmware%2Fcoreboot/statuses/" + gitlabTestSHA: func(w http.ResponseWriter, r *http.Request) {
+			got = nil
+			if decodeRequest(w, r, &got) {
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, `{"id": 1}`)
+			}
+		},
+		"GET /api/v4/projects/firmware%2Fcoreboot/repository/files/.contest%2Fbuild.yaml/raw": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("ref") != gitlabTestSHA {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, "JobName: Build Test")
+		},
+	})
+	defer server.Close()
+	gitlab := GitlabAPI{BaseURL: server.URL, Token: "glpat-test"}
+	ctx := context.Background()
+
+	tests := []struct {
+		state string
+		want  string
+	}{
+		{"pending", "running"},
+		{"success", "success"},
+		{"failure", "failed"},
+		{"error", "failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.state, func(t *testing.T) {
+			err := gitlab.EditGithubStatus(ctx, "firmware", "coreboot", test.state, "https://reports.example.com/1", "Build Test. Test-Report:", "", gitlabTestSHA)
+			if err != nil {
+				t.Fatalf("EditGithubStatus failed: %v", err)
+			}
+			if got["state"] != test.want || got["name"] != "Build Test. Test-Report:" || got["target_url"] != "https://reports.example.com/1" {
+				t.Errorf("got %v want state %s", got, test.want)
+			}
+		})
+	}
+
+	t.Run("unknown state", func(t *testing.T) {
+		if err := gitlab.EditGithubStatus(ctx, "firmware", "coreboot", "skipped", "", "Build Test", "", gitlabTestSHA); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		invalid := GitlabAPI{BaseURL: server.URL, Token: "wrong"}
+		if err := invalid.EditGithubStatus(ctx, "firmware", "coreboot", "success", "", "Build Test", "", gitlabTestSHA); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("file content", func(t *testing.T) {
+		content, err := gitlab.GetFileContent(ctx, "firmware", "coreboot", ".contest/build.yaml", gitlabTestSHA)
+		if err != nil || string(content) != "JobName: Build Test" {
+			t.Errorf("got %q, %v want %q", content, err, "JobName: Build Test")
+		}
+		if _, err := gitlab.GetFileContent(ctx, "firmware", "coreboot", ".contest/missing.yaml", gitlabTestSHA); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v want %v", err, ErrNotFound)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package clientapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxGitlabPayload limits the size of the GitLab webhook payloads
+const maxGitlabPayload = 25 << 20
+
+// gitlabProject is the project of a GitLab webhook
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	GitSSHURL         string `json:"git_ssh_url"`
+	GitHTTPURL        string `json:"git_http_url"`
+}
+
+// gitlabPushEvent is the payload of the GitLab "Push Hook"
+type gitlabPushEvent struct {
+	ObjectKind   string        `json:"object_kind"`
+	Before       string        `json:"before"`
+	After        string        `json:"after"`
+	Ref          string        `json:"ref"`
+	UserUsername string        `json:"user_username"`
+	Project      gitlabProject `json:"project"`
+	Commits      []struct {
+		ID       string   `json:"id"`
+		Message  string   `json:"message"`
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// gitlabMergeRequestEvent is the payload of the GitLab "Merge Request Hook"
+type gitlabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID          int           `json:"iid"`
+		Title        string        `json:"title"`
+		Action       string        `json:"action"`
+		OldRev       string        `json:"oldrev"` // Previous head of an update with new commits, empty for other updates
+		SourceBranch string        `json:"source_branch"`
+		TargetBranch string        `json:"target_branch"`
+		Source       gitlabProject `json:"source"`
+		Target       gitlabProject `json:"target"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// GitlabTrigger receives the push and merge request webhooks of GitLab. The X-Gitlab-Token header has to
+// match the GITLAB_SECRET env variable, GitLab sends the secret token of the webhook unsigned.
+type GitlabTrigger struct {
+}
+
+// Match returns true for webhooks with the event header of GitLab
+func (g GitlabTrigger) Match(r *http.Request) bool {
+	return r.Header.Get("X-Gitlab-Event") != ""
+}
+
+// Parse validates the token of the webhook and returns the event of pushes and of merge requests that were
+// opened, reopened or updated with new commits
+func (g GitlabTrigger) Parse(r *http.Request) (*Event, error) {
+	secret := os.Getenv("GITLAB_SECRET")
+	token := r.Header.Get("X-Gitlab-Token")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return nil, &RequestError{Code: http.StatusUnauthorized, Msg: "invalid webhook token",
+			Err: fmt.Errorf("invalid X-Gitlab-Token of the %s webhook", r.Header.Get("X-Gitlab-Event"))}
+	}
+	payload, err := readPayload(r, maxGitlabPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseGitlabWebhook(r.Header.Get("X-Gitlab-Event"), payload)
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "could not parse webhook", Err: err}
+	}
+	if e != nil && e.DeliveryID == "" {
+		e.DeliveryID = r.Header.Get("X-Gitlab-Event-UUID")
+	}
+	return e, nil
+}
+
+// parseGitlabWebhook maps the push and merge request webhooks of GitLab to events.
+// Other events and merge request actions that do not start jobs return no event.
+func parseGitlabWebhook(eventType string, payload []byte) (*Event, error) {
+	switch eventType {
+	case "Push Hook":
+		var e gitlabPushEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		// Deleted branches have no commit to test
+		if e.After == "" || e.After == "0000000000000000000000000000000000000000" {
+			return nil, nil
+		}
+		event := gitlabPushData(e)
+		event.DeliveryID = CommitDeliveryID(event.Forge, e.Project.PathWithNamespace, event.Branch, event.SHA)
+		return event, nil
+	case "Merge Request Hook":
+		var e gitlabMergeRequestEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		// Updates without oldrev only changed the title, the labels or the description
+		action := e.ObjectAttributes.Action
+		newCommits := action == "update" && e.ObjectAttributes.OldRev != ""
+		if action != "open" && action != "reopen" && !newCommits {
+			return nil, nil
+		}
+		event := gitlabMergeRequestData(e)
+		// New commits of merge requests of the same project are also received as push
+		if newCommits && event.SSHURL == event.BaseSSHURL {
+			event.DeliveryID = CommitDeliveryID(event.Forge, e.Project.PathWithNamespace, event.Branch, event.SHA)
+		}
+		return event, nil
+	}
+	log.Printf("successful received unknown gitlab event %s\n", eventType)
+	return nil, nil
+}
+
+// gitlabPushData returns the commit SHA, the project, the branch and the changed files of the push
+func gitlabPushData(e gitlabPushEvent) *Event {
+	owner, name := splitProjectPath(e.Project.PathWithNamespace)
+	branch := strings.TrimPrefix(e.Ref, "refs/heads/")
+	event := &Event{
+		Forge:        ForgeGitlab,
+		Type:         "push",
+		SHA:          e.After,
+		BaseSHA:      e.Before,
+		SSHURL:       e.Project.GitSSHURL,
+		CloneURL:     e.Project.GitHTTPURL,
+		BaseSSHURL:   e.Project.GitSSHURL,
+		BaseCloneURL: e.Project.GitHTTPURL,
+		Branch:       branch,
+		Ref:          e.Ref,
+		BaseBranch:   branch,
+		RepoOwner:    owner,
+		RepoName:     name,
+		Author:       e.UserUsername,
+	}
+
+	var commitFiles [][]string
+	for _, commit := range e.Commits {
+		if commit.ID == e.After {
+			event.Title = firstLine(commit.Message)
+		}
+		commitFiles = append(commitFiles, commit.Added, commit.Removed, commit.Modified)
+	}
+	event.ChangedFiles = pushedFiles(commitFiles...)
+	return event
+}
+
+// gitlabMergeRequestData returns the source and target of the merge request and the project.
+// The changed files are not part of the payload, the routes match them all.
+func gitlabMergeRequestData(e gitlabMergeRequestEvent) *Event {
+	mr := e.ObjectAttributes
+	owner, name := splitProjectPath(e.Project.PathWithNamespace)
+	return &Event{
+		Forge:        ForgeGitlab,
+		Type:         "pull_request",
+		SHA:          mr.LastCommit.ID,
+		SSHURL:       mr.Source.GitSSHURL,
+		CloneURL:     mr.Source.GitHTTPURL,
+		BaseSSHURL:   mr.Target.GitSSHURL,
+		BaseCloneURL: mr.Target.GitHTTPURL,
+		Branch:       mr.SourceBranch,
+		Ref:          fmt.Sprintf("refs/merge-requests/%d/head", mr.IID),
+		BaseBranch:   mr.TargetBranch,
+		RepoOwner:    owner,
+		RepoName:     name,
+		PRNumber:     mr.IID,
+		Title:        mr.Title,
+		Author:       e.User.Username,
+	}
+}
+
+// splitProjectPath splits the path of a GitLab project into the namespace, which may contain subgroups, and the name
+func splitProjectPath(path string) (string, string) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", path
+	}
+	return path[:i], path[i+1:]
+}
@@ -0,0 +1,266 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// State is the state of a job that is reported to the forge
+type State string
+
+const (
+	StatePending State = "pending" // The job is waiting to be started
+	StateRunning State = "running" // The job was started on the server
+	StateSuccess State = "success" // The job finished successfully
+	StateFailure State = "failure" // The job finished, but the tests failed
+	StateError   State = "error"   // The job could not finish, e.g. it was cancelled or not started
+)
+
+// Status is the status of a job for a commit
+type Status struct {
+	Owner       string // Owner of the repository, may be empty for Gerrit projects
+	Repo        string // Name of the repository
+	SHA         string // Commit the job was started for
+	State       State
+	TargetURL   string // Link to the report of the job, may be empty
+	Context     string // Name of the job, statuses with the same context replace each other
+	Description string // Short description of the state, may be empty
+}
+
+// StatusReporter sets the status of the jobs for a commit on a forge
+type StatusReporter interface {
+	ReportStatus(ctx context.Context, status Status) error
+}
+
+// FileReader is implemented by the status reporters of forges that serve the files of a repository at a commit.
+// If the file does not exist, ErrNotFound is returned.
+type FileReader interface {
+	GetFileContent(ctx context.Context, owner string, repo string, path string, ref string) ([]byte, error)
+}
+
+// ChangedFilesLister is implemented by the status reporters of forges whose webhooks of pull requests
+// do not contain the changed files
+type ChangedFilesLister interface {
+	ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, sha string) ([]string, error)
+}
+
+// StatusCollector is implemented by the status reporters that collect the final statuses of the jobs of a commit
+// and post them together, e.g. as a Gerrit review. FinishStatuses is called after the jobs of a delivery finished,
+// the jobs of the status contexts that did not report a final status yet are posted without a result.
+type StatusCollector interface {
+	FinishStatuses(ctx context.Context, owner string, repo string, sha string, contexts []string) error
+}
+
+// Notifier posts a message about a finished job, e.g. to a chat
+type Notifier interface {
+	Notify(ctx context.Context, msg string) error
+}
+
+// Event is the normalized webhook of a forge, it contains everything that is needed to start the jobs
+type Event struct {
+	Forge        string // Forge the webhook was received from, e.g. ForgeGithub
+	DeliveryID   string // ID of the webhook delivery, the same delivery is only processed once
+	Type         string // "push" or "pull_request", Gerrit patchsets are pull requests
+	SHA          string // Pushed commit or head commit of the pull request
+	BaseSHA      string // Commit before the push or base commit of the pull request
+	SSHURL       string // SSH clone URL of the repository that contains SHA, e.g. a fork
+	CloneURL     string // HTTPS clone URL of the repository that contains SHA
+	BaseSSHURL   string // SSH clone URL of the repository that received the webhook
+	BaseCloneURL string // HTTPS clone URL of the repository that received the webhook
+	Branch       string // Pushed branch or head branch of the pull request, jobs of the same branch supersede each other
+	Ref          string // Full ref of the commit, e.g. refs/heads/main or refs/pull/1/head
+	BaseBranch   string // Pushed branch or base branch of the pull request
+	RepoOwner    string // Owner of the repository, may contain subgroups or be empty for Gerrit
+	RepoName     string // Name of the repository
+	PRNumber     int    // Number of the pull request or Gerrit change, 0 for pushes
+	PatchSet     int    // Number of the patchset of the Gerrit change, 0 for other forges
+	Title        string // Title of the pull request or first line of the head commit message
+	Author       string // Author of the pull request or sender of the push
+	ChangedFiles []string
+	Command      *Command // Slash command of a comment on the pull request, nil for other webhooks
+}
+
+// Trigger turns the webhooks of a forge into normalized events
+type Trigger interface {
+	// Match returns true if the request is a webhook of the forge of the trigger
+	Match(r *http.Request) bool
+	// Parse validates the webhook and returns its event. Webhooks that do not start jobs return a nil event.
+	// Invalid webhooks return a *RequestError with the HTTP status they are answered with.
+	Parse(r *http.Request) (*Event, error)
+}
+
+// RequestError is returned by the triggers for webhooks that are not valid
+type RequestError struct {
+	Code int // HTTP status the webhook is answered with
+	Msg  string
+	Err  error
+}
+
+func (e *RequestError) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// CommitDeliveryID identifies the push of a commit to a branch of the repository "owner/name". New commits on
+// pull requests of the same repository are also received as push webhooks, both events share the ID,
+// so that the commit is only tested for the event that is received first.
+func CommitDeliveryID(forge string, repository string, branch string, sha string) string {
+	return fmt.Sprintf("%s-%s-%s-%s", forge, repository, branch, sha)
+}
+
+// readPayload reads the body of the webhook, payloads larger than limit are rejected
+func readPayload(r *http.Request, limit int64) ([]byte, error) {
+	defer r.Body.Close()
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err == nil && int64(len(payload)) > limit {
+		err = fmt.Errorf("the payload is larger than %d bytes", limit)
+	}
+	if err != nil {
+		return nil, &RequestError{Code: http.StatusBadRequest, Msg: "invalid webhook payload", Err: err}
+	}
+	return payload, nil
+}
+
+// Providers are the active triggers, status reporters and notifiers
+type Providers struct {
+	Triggers  []Trigger                 // The first trigger that matches a webhook parses it
+	Reporters map[string]StatusReporter // Status reporter per forge, statuses of unknown forges are not reported
+	Notifiers []Notifier                // Notified about every finished job
+}
+
+// ErrNoReporter is returned if no status reporter is active for the forge of the job
+var ErrNoReporter = errors.New("no status reporter is active for the forge")
+
+// Trigger returns the trigger of the webhook, nil if no active trigger matches it
+func (p *Providers) Trigger(r *http.Request) Trigger {
+	for _, trigger := range p.Triggers {
+		if trigger.Match(r) {
+			return trigger
+		}
+	}
+	return nil
+}
+
+// Reporter returns the status reporter of the forge. Jobs that were stored before the forge was recorded are from GitHub.
+func (p *Providers) Reporter(forge string) (StatusReporter, error) {
+	if forge == "" {
+		forge = ForgeGithub
+	}
+	reporter, found := p.Reporters[forge]
+	if !found {
+		return nil, fmt.Errorf("%w %s", ErrNoReporter, forge)
+	}
+	return reporter, nil
+}
+
+// ReportStatus reports the status with the reporter of the forge
+func (p *Providers) ReportStatus(ctx context.Context, forge string, status Status) error {
+	reporter, err := p.Reporter(forge)
+	if err != nil {
+		return err
+	}
+	return reporter.ReportStatus(ctx, status)
+}
+
+// FinishStatuses posts the collected statuses of the jobs of the commit, if the reporter of the forge collects them
+func (p *Providers) FinishStatuses(ctx context.Context, forge string, owner string, repo string, sha string, contexts []string) error {
+	reporter, err := p.Reporter(forge)
+	if err != nil {
+		return err
+	}
+	if collector, ok := reporter.(StatusCollector); ok {
+		return collector.FinishStatuses(ctx, owner, repo, sha, contexts)
+	}
+	return nil
+}
+
+// Notify posts the message with all notifiers, the first error is returned
+func (p *Providers) Notify(ctx context.Context, msg string) error {
+	var firstErr error
+	for _, notifier := range p.Notifiers {
+		if err := notifier.Notify(ctx, msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NewProviders creates the providers that are selected in the client descriptor. Without a selection
+// webhooks of all forges are received and the finished jobs are posted to Slack.
+func NewProviders(cd client.ClientDescriptor) (*Providers, error) {
+	forges := cd.Providers.Forges
+	if len(forges) == 0 {
+		forges = []string{ForgeGerrit, ForgeGitlab, ForgeGitea, ForgeGithub}
+	}
+	p := &Providers{Reporters: make(map[string]StatusReporter)}
+	for _, forge := range forges {
+		switch forge {
+		case ForgeGithub:
+			p.Triggers = append(p.Triggers, GithubTrigger{})
+			p.Reporters[forge] = GithubAPI{}
+		case ForgeGitlab:
+			p.Triggers = append(p.Triggers, GitlabTrigger{})
+			p.Reporters[forge] = GitlabAPI{}
+		case ForgeGerrit:
+			if cd.Gerrit.WebhookPath != "" {
+				p.Triggers = append(p.Triggers, GerritTrigger{Gerrit: cd.Gerrit})
+			}
+			p.Reporters[forge] = GerritAPI{}
+		case ForgeGitea:
+			p.Triggers = append(p.Triggers, GiteaTrigger{Instances: cd.Gitea})
+			p.Reporters[forge] = GiteaAPI{}
+		default:
+			return nil, fmt.Errorf("unknown forge %q, use %s, %s, %s or %s", forge, ForgeGithub, ForgeGitlab, ForgeGerrit, ForgeGitea)
+		}
+	}
+
+	notifiers := cd.Providers.Notifiers
+	if notifiers == nil {
+		notifiers = []string{"slack"}
+	}
+	for _, notifier := range notifiers {
+		switch notifier {
+		case "slack":
+			p.Notifiers = append(p.Notifiers, SlackAPI{})
+		default:
+			return nil, fmt.Errorf("unknown notifier %q, use slack", notifier)
+		}
+	}
+	return p, nil
+}
+
+// active are the providers that are used by the client and the plugins
+var active = struct {
+	lock      sync.Mutex
+	providers *Providers
+}{}
+
+// SetProviders activates the providers, e.g. fakes in tests
+func SetProviders(p *Providers) {
+	active.lock.Lock()
+	defer active.lock.Unlock()
+	active.providers = p
+}
+
+// ActiveProviders returns the active providers. If none were set, the providers of all forges are active.
+func ActiveProviders() *Providers {
+	active.lock.Lock()
+	defer active.lock.Unlock()
+	if active.providers == nil {
+		active.providers, _ = NewProviders(client.ClientDescriptor{})
+	}
+	return active.providers
+}
@@ -0,0 +1,80 @@
+package clientapi
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// fakeReporter records the reported statuses
+type fakeReporter struct {
+	statuses []Status
+}
+
+func (f *fakeReporter) ReportStatus(ctx context.Context, status Status) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+// Test for NewProviders, if the forges and notifiers of the config are activated
+func TestNewProviders(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		p, err := NewProviders(client.ClientDescriptor{})
+		if err != nil {
+			t.Fatalf("NewProviders failed: %v", err)
+		}
+		// The webhooks plugin of Gerrit is only received on its path
+		if got, want := len(p.Triggers), 3; got != want {
+			t.Errorf("got %d triggers want %d", got, want)
+		}
+		for _, forge := range []string{"", ForgeGithub, ForgeGitlab, ForgeGerrit, ForgeGitea} {
+			if _, err := p.Reporter(forge); err != nil {
+				t.Errorf("got %v want a reporter for %q", err, forge)
+			}
+		}
+		if got, want := p.Notifiers, []Notifier{SlackAPI{}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+	t.Run("selected", func(t *testing.T) {
+		p, err := NewProviders(client.ClientDescriptor{Providers: client.Providers{Forges: []string{ForgeGitea}, Notifiers: []string{}}})
+		if err != nil {
+			t.Fatalf("NewProviders failed: %v", err)
+		}
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("X-GitHub-Event", "push")
+		if trigger := p.Trigger(req); trigger != nil {
+			t.Errorf("got %T want no trigger for GitHub", trigger)
+		}
+		req.Header.Set("X-Gitea-Event", "push")
+		if trigger, ok := p.Trigger(req).(GiteaTrigger); !ok {
+			t.Errorf("got %T want %T", trigger, GiteaTrigger{})
+		}
+		if _, err := p.Reporter(""); !errors.Is(err, ErrNoReporter) {
+			t.Errorf("got %v want %v", err, ErrNoReporter)
+		}
+		if len(p.Notifiers) != 0 {
+			t.Errorf("got %v want no notifiers", p.Notifiers)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := NewProviders(client.ClientDescriptor{Providers: client.Providers{Forges: []string{"bitbucket"}}}); err == nil {
+			t.Errorf("got nil want an error")
+		}
+	})
+	t.Run("fake reporter", func(t *testing.T) {
+		fake := &fakeReporter{}
+		p := &Providers{Reporters: map[string]StatusReporter{ForgeGithub: fake}}
+		status := Status{Owner: "9elements", Repo: "coreboot", SHA: sha, State: StateRunning, Context: "Build Test"}
+		if err := p.ReportStatus(context.Background(), "", status); err != nil {
+			t.Fatalf("ReportStatus failed: %v", err)
+		}
+		if want := []Status{status}; !reflect.DeepEqual(fake.statuses, want) {
+			t.Errorf("got %v want %v", fake.statuses, want)
+		}
+	})
+}
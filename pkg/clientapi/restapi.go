@@ -0,0 +1,81 @@
+package clientapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// restAPI sends the requests to the JSON REST API of a forge, e.g. GitLab, Gitea or Gerrit
+type restAPI struct {
+	baseURL    string                  // Base URL of the forge
+	httpClient *http.Client            // HTTP client of the requests, a client with a timeout of 30s is used if it is nil
+	authorize  func(req *http.Request) // Sets the credentials of the account on the request
+	jsonPrefix string                  // Prefix of the JSON responses that is removed, e.g. the XSSI prefix of Gerrit
+}
+
+// apiStatusError is returned if the REST API of a forge answers with an error status
+type apiStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *apiStatusError) Error() string {
+	return e.msg
+}
+
+// rawBody receives the response of the REST API without decoding it
+type rawBody struct {
+	content *[]byte
+}
+
+// request sends the JSON body to the REST API and decodes the JSON response into v, if it is not nil.
+// Responses with an error status are returned as apiStatusError.
+func (api restAPI) request(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	httpClient := api.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(api.baseURL, "/")+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if api.authorize != nil {
+		api.authorize(req)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &apiStatusError{code: resp.StatusCode, msg: fmt.Sprintf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *rawBody:
+		*v.content = respBody
+		return nil
+	}
+	return json.Unmarshal(bytes.TrimPrefix(respBody, []byte(api.jsonPrefix)), v)
+}
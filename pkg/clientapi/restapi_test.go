@@ -0,0 +1,89 @@
+package clientapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newFakeForge starts a fake REST API of a forge. Requests that are not authorized are answered with 401,
+// the others by the route of "METHOD /escaped/path" or with 404 if there is none
+func newFakeForge(authorized func(r *http.Request) bool, routes map[string]http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			http.Error(w, `{"message": "401 Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		route, found := routes[r.Method+" "+r.URL.EscapedPath()]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		route(w, r)
+	}))
+}
+
+// decodeRequest decodes the JSON body of the request into v, it answers with 400 and returns false if that fails
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Test for restAPI, if the credentials are set, the prefix is removed and error statuses are returned as apiStatusError
+func TestRestAPI(t *testing.T) {
+	server := newFakeForge(func(r *http.Request) bool {
+		return r.Header.Get("PRIVATE-TOKEN") == "secret"
+	}, map[string]http.HandlerFunc{
+		"POST /echo": func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			if decodeRequest(w, r, &body) {
+				fmt.Fprintf(w, ")]}'\n{\"state\": %q}", body["state"])
+			}
+		},
+		"GET /raw": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "JobName: Build Test")
+		},
+	})
+	defer server.Close()
+	api := restAPI{baseURL: server.URL + "/", jsonPrefix: ")]}'", authorize: func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", "secret")
+	}}
+	ctx := context.Background()
+
+	t.Run("json", func(t *testing.T) {
+		var got map[string]string
+		err := api.request(ctx, http.MethodPost, "/echo", map[string]string{"state": "success"}, &got)
+		if want := map[string]string{"state": "success"}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, %v want %v", got, err, want)
+		}
+	})
+	t.Run("raw", func(t *testing.T) {
+		var got []byte
+		err := api.request(ctx, http.MethodGet, "/raw", nil, &rawBody{content: &got})
+		if err != nil || string(got) != "JobName: Build Test" {
+			t.Errorf("got %q, %v want %q", got, err, "JobName: Build Test")
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		var statusErr *apiStatusError
+		err := api.request(ctx, http.MethodGet, "/missing", nil, nil)
+		if !errors.As(err, &statusErr) || statusErr.code != http.StatusNotFound {
+			t.Errorf("got %v want status %d", err, http.StatusNotFound)
+		}
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		var statusErr *apiStatusError
+		err := restAPI{baseURL: server.URL}.request(ctx, http.MethodGet, "/raw", nil, nil)
+		if !errors.As(err, &statusErr) || statusErr.code != http.StatusUnauthorized {
+			t.Errorf("got %v want status %d", err, http.StatusUnauthorized)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package clientapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,6 +17,11 @@ type RequestBody struct {
 type SlackAPI struct {
 }
 
+// Notify posts the message to the Slack webhook in the SLACK_WEBHOOK_URL env variable
+func (s SlackAPI) Notify(ctx context.Context, msg string) error {
+	return s.MsgToSlack(msg)
+}
+
 // MsgToSlack will post a message to a slack webhook. It receives a message and post it.
 func (s SlackAPI) MsgToSlack(msg string) error {
 	// Getting env variable SLACK_WEBHOOK_URL
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// retention defines how long finished deliveries are kept in the store
+const retention = 7 * 24 * time.Hour
+
+// Delivery contains the state of a single webhook delivery
+type Delivery struct {
+	ID        string           // Unique ID of the delivery, e.g. the X-GitHub-Delivery header
+	Received  time.Time        // Time the webhook was received
+	Webhook   json.RawMessage  // Serialized webhook data, the store does not interpret it
+	Templates []string         // Job templates of the pipeline, selected before the first job was started
+	Jobs      []client.RunData // Jobs that were started for the delivery
+	HooksDone []string         // Names of the PostJobExecutionHooks that finished
+	Attempts  int              // Number of times the processing of the delivery was started
+	Done      bool             // True if the delivery was processed completely or failed permanently
+}
+
+// HookDone returns true if the PostJobExecutionHook already finished for this delivery
+func (d *Delivery) HookDone(name string) bool {
+	for _, hook := range d.HooksDone {
+		if hook == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Store is a file-backed store for the webhook deliveries. Every change is written to disk
+// immediately, so that the client can resume the processing of the deliveries after a restart.
+type Store struct {
+	lock       sync.Mutex
+	path       string
+	deliveries map[string]*Delivery
+}
+
+// Open loads the store from the file at path. If the file does not exist, an empty store is created.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:       path,
+		deliveries: make(map[string]*Delivery),
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("could not read the state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.deliveries); err != nil {
+		return nil, fmt.Errorf("could not decode the state file: %w", err)
+	}
+
+	// Drop finished deliveries that are older than the retention time
+	for id, delivery := range s.deliveries {
+		if delivery.Done && time.Since(delivery.Received) > retention {
+			delete(s.deliveries, id)
+		}
+	}
+	return s, nil
+}
+
+// AddDelivery records a new delivery. It returns false if a delivery with the same ID is already known.
+func (s *Store) AddDelivery(id string, webhook json.RawMessage) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.deliveries[id]; found {
+		return false, nil
+	}
+	s.deliveries[id] = &Delivery{ID: id, Received: time.Now(), Webhook: webhook}
+	return true, s.save()
+}
+
+// RemoveDelivery forgets a delivery, e.g. if it could not be queued
+func (s *Store) RemoveDelivery(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.deliveries, id)
+	return s.save()
+}
+
+// Delivery returns a copy of the delivery with the given ID
+func (s *Store) Delivery(id string) (Delivery, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delivery, found := s.deliveries[id]
+	if !found {
+		return Delivery{}, false
+	}
+	return copyDelivery(delivery), true
+}
+
+// SetWebhook replaces the webhook data of the delivery and records its job templates, e.g. after the command
+// of a comment was acknowledged and selected the head commit and the templates
+func (s *Store) SetWebhook(id string, webhook json.RawMessage, templates []string) error {
+	return s.update(id, func(delivery *Delivery) {
+		delivery.Webhook = webhook
+		delivery.Templates = append([]string(nil), templates...)
+	})
+}
+
+// SetTemplates records the job templates of the pipeline of the delivery
+func (s *Store) SetTemplates(id string, templates []string) error {
+	return s.update(id, func(delivery *Delivery) {
+		delivery.Templates = append([]string(nil), templates...)
+	})
+}
+
+// SetJobs records the jobs that were started for the delivery
+func (s *Store) SetJobs(id string, jobs []client.RunData) error {
+	return s.update(id, func(delivery *Delivery) {
+		delivery.Jobs = append([]client.RunData(nil), jobs...)
+	})
+}
+
+// SetHookDone records that the PostJobExecutionHook finished for the delivery
+func (s *Store) SetHookDone(id string, name string) error {
+	return s.update(id, func(delivery *Delivery) {
+		if !delivery.HookDone(name) {
+			delivery.HooksDone = append(delivery.HooksDone, name)
+		}
+	})
+}
+
+// StartAttempt records that the processing of the delivery is started and returns the number of the attempt
+func (s *Store) StartAttempt(id string) (int, error) {
+	var attempts int
+	err := s.update(id, func(delivery *Delivery) {
+		delivery.Attempts++
+		attempts = delivery.Attempts
+	})
+	return attempts, err
+}
+
+// SetDone records that the delivery was processed completely
+func (s *Store) SetDone(id string) error {
+	return s.update(id, func(delivery *Delivery) {
+		delivery.Done = true
+	})
+}
+
+// Pending returns all deliveries that were not processed completely, the oldest first
+func (s *Store) Pending() []Delivery {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var pending []Delivery
+	for _, delivery := range s.deliveries {
+		if !delivery.Done {
+			pending = append(pending, copyDelivery(delivery))
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Received.Before(pending[j].Received)
+	})
+	return pending
+}
+
+// CommitJobs returns the jobs of all deliveries that were started for the commit of the repository "owner/name",
+// the jobs of the oldest delivery first
+func (s *Store) CommitJobs(owner string, name string, sha string) []client.RunData {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var deliveries []*Delivery
+	for _, delivery := range s.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Received.Before(deliveries[j].Received)
+	})
+	var jobs []client.RunData
+	for _, delivery := range deliveries {
+		for _, jobData := range delivery.Jobs {
+			if jobData.JobSHA == sha && jobData.RepoOwner == owner && jobData.RepoName == name {
+				jobs = append(jobs, jobData)
+			}
+		}
+	}
+	return jobs
+}
+
+// update applies the change to the delivery and saves the store
+func (s *Store) update(id string, change func(delivery *Delivery)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delivery, found := s.deliveries[id]
+	if !found {
+		return fmt.Errorf("delivery %s is not known", id)
+	}
+	change(delivery)
+	return s.save()
+}
+
+// save writes the store to a temporary file and renames it, so that the state file
+// is never left half written. The caller has to hold the lock.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.deliveries, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not encode the state: %w", err)
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create the temporary state file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("could not write the state file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("could not write the state file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		return fmt.Errorf("could not replace the state file: %w", err)
+	}
+	return nil
+}
+
+// copyDelivery returns a deep copy of the delivery that can be used without holding the lock
+func copyDelivery(delivery *Delivery) Delivery {
+	c := *delivery
+	c.Templates = append([]string(nil), delivery.Templates...)
+	c.Jobs = append([]client.RunData(nil), delivery.Jobs...)
+	c.HooksDone = append([]string(nil), delivery.HooksDone...)
+	return c
+}
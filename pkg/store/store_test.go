@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/contest-client/pkg/client"
+)
+
+// Test for the Store, if the state of the deliveries survives a restart
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	jobs := []client.RunData{{JobID: 42, JobName: "Build Test", JobSHA: "a94a8fe5ccb19ba61c4c0873daa391e9872fbbd3"}}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("function 'Open' returned an error: %v", err)
+	}
+	added, err := s.AddDelivery("delivery-1", json.RawMessage(`{"HeadSHA":"a94a8fe"}`))
+	if err != nil || !added {
+		t.Fatalf("could not add the delivery: added %t, err %v", added, err)
+	}
+	// A redelivery must not be added twice
+	if added, _ := s.AddDelivery("delivery-1", nil); added {
+		t.Errorf("the delivery was added twice")
+	}
+	if _, err := s.AddDelivery("delivery-2", nil); err != nil {
+		t.Fatalf("could not add the delivery: %v", err)
+	}
+	if err := s.SetTemplates("delivery-1", []string{"build.yaml"}); err != nil {
+		t.Fatalf("function 'SetTemplates' returned an error: %v", err)
+	}
+	// The command of a comment replaces the webhook with the head commit of the pull request
+	if err := s.SetWebhook("delivery-1", json.RawMessage(`{"HeadSHA":"da15608"}`), []string{"archercity.yaml"}); err != nil {
+		t.Fatalf("function 'SetWebhook' returned an error: %v", err)
+	}
+	if err := s.SetJobs("delivery-1", jobs); err != nil {
+		t.Fatalf("function 'SetJobs' returned an error: %v", err)
+	}
+	if err := s.SetHookDone("delivery-1", "pushtoS3"); err != nil {
+		t.Fatalf("function 'SetHookDone' returned an error: %v", err)
+	}
+	if attempt, err := s.StartAttempt("delivery-1"); err != nil || attempt != 1 {
+		t.Fatalf("got attempt %d, %v want 1", attempt, err)
+	}
+	if err := s.SetDone("delivery-2"); err != nil {
+		t.Fatalf("function 'SetDone' returned an error: %v", err)
+	}
+
+	// Reopen the store and check the pending deliveries
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("function 'Open' returned an error: %v", err)
+	}
+	pending := s.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("got %d pending deliveries want 1", len(pending))
+	}
+	got := pending[0]
+	if got.ID != "delivery-1" || !reflect.DeepEqual(got.Jobs, jobs) || !reflect.DeepEqual(got.Templates, []string{"archercity.yaml"}) || !got.HookDone("pushtoS3") || got.Attempts != 1 {
+		t.Errorf("got %+v want the delivery-1 with its templates, jobs, the finished hook and one attempt", got)
+	}
+	var webhook struct{ HeadSHA string }
+	if err := json.Unmarshal(got.Webhook, &webhook); err != nil || webhook.HeadSHA != "da15608" {
+		t.Errorf("got %s want the stored webhook", got.Webhook)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/9elements/contest-client/pkg/client/clientpluginregistry"
 	"github.com/facebookincubator/contest/pkg/xcontext"
 
+	"github.com/9elements/contest-client/plugins/postjobexecutionhooks/githubchecks"
 	"github.com/9elements/contest-client/plugins/postjobexecutionhooks/pushtoS3"
 	noop "github.com/9elements/contest-client/plugins/prejobexecutionhooks/noop"
 )
@@ -16,6 +17,7 @@ var PreExecutionHooks = []client.PreJobExecutionHookLoader{
 
 var PostExecutionHooks = []client.PostJobExecutionHookLoader{
 	pushtoS3.Load,
+	githubchecks.Load,
 }
 
 // Init initializes the client plugin registry
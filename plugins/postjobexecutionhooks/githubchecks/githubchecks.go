@@ -0,0 +1,176 @@
+package githubchecks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/job"
+	"github.com/facebookincubator/contest/pkg/transport"
+)
+
+// Name defines the name of the postexecutionhook used within the plugin registry
+var Name = "githubchecks"
+
+// GithubChecks reports every job as a GitHub check run
+type GithubChecks struct {
+	DetailsURL     string // Link that is shown in the check run, e.g. to a ConTest dashboard
+	AnnotationPath string // File path the annotations of failed steps are attached to, default ".contest"
+}
+
+// ValidateParameters validates the parameters for the post execution hook
+func (g *GithubChecks) ValidateParameters(params []byte) (interface{}, error) {
+	var checksParam GithubChecks
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &checksParam); err != nil {
+			return nil, fmt.Errorf("GithubChecks could not unmarshal the parameter while validating them: %w", err)
+		}
+	}
+	if checksParam.AnnotationPath == "" {
+		checksParam.AnnotationPath = ".contest"
+	}
+	return checksParam, nil
+}
+
+// Name returns the Name of the post execution hook
+func (g *GithubChecks) Name() string {
+	return Name
+}
+
+// JobStarted creates the check run of the job as soon as it was started and records its ID in the RunData
+func (g *GithubChecks) JobStarted(ctx context.Context, parameter interface{}, cd client.ClientDescriptor, jobData *client.RunData) error {
+	return StartCheckRun(ctx, clientapi.GithubChecksAPI{}, parameter.(GithubChecks), jobData)
+}
+
+// Run updates the check run of every job until the job finished. The jobs are reported concurrently.
+func (g *GithubChecks) Run(ctx context.Context, parameter interface{}, cd client.ClientDescriptor, transport transport.Transport,
+	rundata []client.RunData) (interface{}, error) {
+
+	// Retrieving the parameter
+	var checksParam GithubChecks = parameter.(GithubChecks)
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+	)
+	for _, jobData := range rundata {
+		// Check runs only exist on GitHub
+		if jobData.Forge != "" && jobData.Forge != clientapi.ForgeGithub {
+			continue
+		}
+		// The check run is created when the job is started, jobs without one are not reported
+		if jobData.CheckRunID == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(jobData client.RunData) {
+			defer wg.Done()
+			if err := ReportCheckRun(ctx, cd, transport, clientapi.GithubChecksAPI{}, checksParam, jobData); err != nil {
+				lock.Lock()
+				errs = append(errs, fmt.Errorf("GithubChecks in job %d did not finished: %w", jobData.JobID, err))
+				lock.Unlock()
+			}
+		}(jobData)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, errors.New(strings.Join(msgs, "; "))
+	}
+	return nil, nil
+}
+
+// StartCheckRun creates the check run of a job that was triggered on GitHub and records its ID in the RunData
+func StartCheckRun(ctx context.Context, checks clientapi.GithubChecks, parameter GithubChecks, runData *client.RunData) error {
+	// Check runs only exist on GitHub
+	if runData.Forge != "" && runData.Forge != clientapi.ForgeGithub {
+		return nil
+	}
+	checkRunID, err := checks.CreateCheckRun(ctx, runData.RepoOwner, runData.RepoName, runData.JobName, runData.JobSHA, parameter.DetailsURL)
+	if err != nil {
+		return fmt.Errorf("could not create the check run of job %d: %w", runData.JobID, err)
+	}
+	runData.CheckRunID = checkRunID
+	return nil
+}
+
+// ReportCheckRun updates the check run of the job while the job runs and posts the report as soon as the job finished
+func ReportCheckRun(ctx context.Context, cd client.ClientDescriptor, transport transport.Transport,
+	checks clientapi.GithubChecks, parameter GithubChecks, runData client.RunData) error {
+
+	checkRunID := runData.CheckRunID
+	tracker := client.NewJobTracker(cd, transport)
+	jobWaitPoll := time.Duration(*cd.Flags.FlagjobWaitPoll) * time.Second
+	var lastSummary string
+	for {
+		finished, err := tracker.JobFinished(ctx, runData.JobID)
+		if err != nil {
+			return err
+		}
+		statusResp, err := client.JobStatus(ctx, transport, *cd.Flags.FlagRequestor, runData.JobID)
+		if err != nil {
+			return err
+		}
+		status := statusResp.Data.Status
+		output, err := BuildCheckRunOutput(runData, status, parameter.AnnotationPath)
+		if err != nil {
+			return err
+		}
+
+		// Post the final report with the conclusion of the job
+		if finished {
+			update := clientapi.CheckRunUpdate{Status: "completed", Conclusion: conclusion(status), Output: output}
+			return checks.UpdateCheckRun(ctx, runData.RepoOwner, runData.RepoName, checkRunID, update)
+		}
+
+		// Update the progress of the running job, if anything changed. GitHub appends the annotations
+		// of every update, they are only sent with the final report.
+		if output.Summary != lastSummary {
+			output.Annotations = nil
+			update := clientapi.CheckRunUpdate{Status: "in_progress", Output: output}
+			if err := checks.UpdateCheckRun(ctx, runData.RepoOwner, runData.RepoName, checkRunID, update); err != nil {
+				return err
+			}
+			lastSummary = output.Summary
+		}
+
+		// Sleep for the time thats configured in the clientconfig.json and than continue
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jobWaitPoll):
+		}
+	}
+}
+
+// conclusion maps the final state of the job to the conclusion of the check run
+func conclusion(status *job.Status) string {
+	switch {
+	case client.JobSucceeded(status):
+		return "success"
+	case status.State == string(job.EventJobCancelled):
+		return "cancelled"
+	default:
+		return "failure"
+	}
+}
+
+// New builds a new GithubChecks post execution hook
+func New() client.PostJobExecutionHooks {
+	return &GithubChecks{}
+}
+
+// Load returns the name and factory which are needed to register the post execution hook
+func Load() (string, client.PostJobExecutionHooksFactory) {
+	return Name, New
+}
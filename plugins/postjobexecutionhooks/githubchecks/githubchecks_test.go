@@ -0,0 +1,82 @@
+package githubchecks
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+)
+
+// Test for stepReport, if every test step gets a row and failed commands become annotations
+func TestStepReport(t *testing.T) {
+	statusJSON, err := ioutil.ReadFile("testdata/status.json")
+	if err != nil {
+		t.Fatalf("could not read the job status: %v", err)
+	}
+
+	text, annotations, err := stepReport(statusJSON, ".contest")
+	if err != nil {
+		t.Fatalf("function 'stepReport' returned an error: %v", err)
+	}
+
+	for _, row := range []string{
+		"| cmd | Cloning coreboot | 1 |  |  |",
+		"| cmd | Build coreboot | 1 | yv3-evt-slot1 | yv3-evt-slot1: exit status 2 |",
+		"| s3fileupload | upload coreboot binary | 1 | yv3-evt-slot1 | yv3-evt-slot1: file not found |",
+	} {
+		if !strings.Contains(text, row) {
+			t.Errorf("the report does not contain the row %q:\n%s", row, text)
+		}
+	}
+
+	// Only the failed cmd step is annotated
+	if len(annotations) != 1 {
+		t.Fatalf("got %d annotations want 1", len(annotations))
+	}
+	got := annotations[0]
+	if got.Path != ".contest" || got.AnnotationLevel != "failure" || got.Title != "Build coreboot failed on yv3-evt-slot1" {
+		t.Errorf("got %+v want an annotation for the failed build step", got)
+	}
+}
+
+// fakeChecks records the check runs that were created
+type fakeChecks struct {
+	created []string
+}
+
+func (f *fakeChecks) CreateCheckRun(ctx context.Context, owner string, repo string, name string, sha string, detailsURL string) (int64, error) {
+	f.created = append(f.created, name)
+	return int64(len(f.created)), nil
+}
+
+func (f *fakeChecks) UpdateCheckRun(ctx context.Context, owner string, repo string, checkRunID int64, update clientapi.CheckRunUpdate) error {
+	return nil
+}
+
+// Test for StartCheckRun, if the ID of the check run is recorded and only jobs of GitHub get one
+func TestStartCheckRun(t *testing.T) {
+	checks := &fakeChecks{}
+	github := client.RunData{JobID: 1, JobName: "Build Test"}
+	if err := StartCheckRun(context.Background(), checks, GithubChecks{}, &github); err != nil || github.CheckRunID != 1 {
+		t.Errorf("got check run %d, %v want 1", github.CheckRunID, err)
+	}
+	gitlab := client.RunData{JobID: 2, JobName: "Boot Test", Forge: clientapi.ForgeGitlab}
+	if err := StartCheckRun(context.Background(), checks, GithubChecks{}, &gitlab); err != nil || gitlab.CheckRunID != 0 {
+		t.Errorf("got check run %d, %v want 0", gitlab.CheckRunID, err)
+	}
+	if len(checks.created) != 1 {
+		t.Errorf("got %v want a single check run", checks.created)
+	}
+}
+
+// Test for truncate, if a long report is cut on a rune boundary
+func TestTruncate(t *testing.T) {
+	got := truncate(strings.Repeat("ü", maxOutputLength))
+	if len(got) > maxOutputLength || !utf8.ValidString(got) {
+		t.Errorf("got a report of %d bytes, valid UTF-8 %v want at most %d bytes of valid UTF-8", len(got), utf8.ValidString(got), maxOutputLength)
+	}
+}
@@ -0,0 +1,207 @@
+package githubchecks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/9elements/contest-client/pkg/client"
+	"github.com/9elements/contest-client/pkg/clientapi"
+	"github.com/facebookincubator/contest/pkg/job"
+)
+
+// maxOutputLength is the maximum length of the summary and the text of a check run
+const maxOutputLength = 65535
+
+// The per step results are decoded from the JSON of the job status, since only a few
+// fields of the run statuses are needed for the report
+type stepStatuses struct {
+	RunStatus   *runStatus
+	RunStatuses []runStatus
+}
+
+type runStatus struct {
+	RunID        uint64
+	TestStatuses []testStatus
+}
+
+type testStatus struct {
+	TestName         string
+	TestStepStatuses []testStepStatus
+}
+
+type testStepStatus struct {
+	TestStepName   string
+	TestStepLabel  string
+	TargetStatuses []targetStatus
+}
+
+type targetStatus struct {
+	Target *struct {
+		ID string
+	}
+	Error string
+}
+
+// BuildCheckRunOutput builds the Markdown report of the job from its run and final reports and
+// a table per test step. Failed cmd and sshcmd steps are added as annotations.
+func BuildCheckRunOutput(runData client.RunData, status *job.Status, annotationPath string) (*clientapi.CheckRunOutput, error) {
+	output := &clientapi.CheckRunOutput{}
+
+	// Title
+	switch {
+	case !client.JobCompleted(status):
+		output.Title = "Running"
+	case client.JobSucceeded(status):
+		output.Title = "Passed"
+	default:
+		output.Title = "Failed"
+	}
+
+	// Summary with the run and final reports
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "**Job:** %s (ID %d)\n\n", runData.JobName, runData.JobID)
+	if len(runData.Tags) > 0 {
+		fmt.Fprintf(&summary, "**Tags:** %s\n\n", strings.Join(runData.Tags, ", "))
+	}
+	fmt.Fprintf(&summary, "**State:** %s\n\n", status.State)
+	if status.StateErrMsg != "" {
+		fmt.Fprintf(&summary, "**Error:** %s\n\n", status.StateErrMsg)
+	}
+	if status.JobReport != nil {
+		if len(status.JobReport.RunReports) > 0 {
+			summary.WriteString("### Run reports\n\n| Run | Reporter | Success | Details |\n| --- | --- | --- | --- |\n")
+			for run, reports := range status.JobReport.RunReports {
+				for _, report := range reports {
+					fmt.Fprintf(&summary, "| %d | %s | %s | %s |\n", run+1, report.ReporterName, successMark(report.Success), tableCell(report.Data))
+				}
+			}
+			summary.WriteString("\n")
+		}
+		if len(status.JobReport.FinalReports) > 0 {
+			summary.WriteString("### Final reports\n\n| Reporter | Success | Details |\n| --- | --- | --- |\n")
+			for _, report := range status.JobReport.FinalReports {
+				fmt.Fprintf(&summary, "| %s | %s | %s |\n", report.ReporterName, successMark(report.Success), tableCell(report.Data))
+			}
+			summary.WriteString("\n")
+		}
+	}
+	output.Summary = truncate(summary.String())
+
+	// Text with a table per test step and the annotations of the failed steps
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the job status: %w", err)
+	}
+	text, annotations, err := stepReport(statusJSON, annotationPath)
+	if err != nil {
+		return nil, err
+	}
+	output.Text = truncate(text)
+	output.Annotations = annotations
+
+	return output, nil
+}
+
+// stepReport builds a Markdown table per test of every run with the results of the test steps.
+// The failed targets of cmd and sshcmd steps are returned as annotations.
+func stepReport(statusJSON []byte, annotationPath string) (string, []clientapi.CheckRunAnnotation, error) {
+	var steps stepStatuses
+	if err := json.Unmarshal(statusJSON, &steps); err != nil {
+		return "", nil, fmt.Errorf("could not decode the step statuses: %w", err)
+	}
+	runs := steps.RunStatuses
+	if len(runs) == 0 && steps.RunStatus != nil {
+		runs = []runStatus{*steps.RunStatus}
+	}
+
+	var (
+		text        strings.Builder
+		annotations []clientapi.CheckRunAnnotation
+	)
+	for _, run := range runs {
+		for _, test := range run.TestStatuses {
+			fmt.Fprintf(&text, "### Run %d: %s\n\n| Step | Label | Targets | Failed | Errors |\n| --- | --- | --- | --- | --- |\n", run.RunID, test.TestName)
+			for _, step := range test.TestStepStatuses {
+				var failed []string
+				var errs []string
+				for _, target := range step.TargetStatuses {
+					if target.Error == "" {
+						continue
+					}
+					targetID := "unknown"
+					if target.Target != nil {
+						targetID = target.Target.ID
+					}
+					failed = append(failed, targetID)
+					errs = append(errs, fmt.Sprintf("%s: %s", targetID, target.Error))
+
+					// Failed commands are shown as annotations
+					if isCommandStep(step.TestStepName) && len(annotations) < clientapi.MaxCheckRunAnnotations {
+						annotations = append(annotations, clientapi.CheckRunAnnotation{
+							Path:            annotationPath,
+							StartLine:       1,
+							EndLine:         1,
+							AnnotationLevel: "failure",
+							Title:           fmt.Sprintf("%s failed on %s", step.TestStepLabel, targetID),
+							Message:         fmt.Sprintf("Run %d, test '%s', step '%s' (%s): %s", run.RunID, test.TestName, step.TestStepLabel, step.TestStepName, target.Error),
+						})
+					}
+				}
+				fmt.Fprintf(&text, "| %s | %s | %d | %s | %s |\n", step.TestStepName, tableCell(step.TestStepLabel),
+					len(step.TargetStatuses), tableCell(strings.Join(failed, ", ")), tableCell(strings.Join(errs, "; ")))
+			}
+			text.WriteString("\n")
+		}
+	}
+	return text.String(), annotations, nil
+}
+
+// isCommandStep returns true for the test steps that run commands on the targets
+func isCommandStep(stepName string) bool {
+	return strings.EqualFold(stepName, "cmd") || strings.EqualFold(stepName, "sshcmd")
+}
+
+// successMark returns an emoji for the success of a report
+func successMark(success bool) string {
+	if success {
+		return ":white_check_mark:"
+	}
+	return ":x:"
+}
+
+// tableCell formats a value so that it fits into a single Markdown table cell
+func tableCell(value interface{}) string {
+	var cell string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		cell = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			cell = fmt.Sprintf("%v", v)
+		} else {
+			cell = string(data)
+		}
+	}
+	cell = strings.ReplaceAll(cell, "|", "\\|")
+	cell = strings.ReplaceAll(cell, "\r", "")
+	return strings.ReplaceAll(cell, "\n", "<br>")
+}
+
+// truncate shortens the output to the maximum length GitHub accepts
+func truncate(output string) string {
+	if len(output) <= maxOutputLength {
+		return output
+	}
+	const suffix = "\n\n*The report was truncated.*"
+	// Cut on a rune boundary, the report must stay valid UTF-8
+	end := maxOutputLength - len(suffix)
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + suffix
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -18,10 +17,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/facebookincubator/contest/pkg/api"
 	"github.com/facebookincubator/contest/pkg/job"
 	"github.com/facebookincubator/contest/pkg/transport"
-	"github.com/facebookincubator/contest/pkg/types"
 )
 
 // Function that upload the job report into a S3 bucket
@@ -34,70 +31,65 @@ func PushReportsToS3(ctx context.Context, cd client.ClientDescriptor,
 		return err
 	}
 
-	// Creating link to read out the status of the running job from an api
-	readJobStatus := strings.Join([]string{*cd.Flags.FlagAddr, *cd.Flags.FlagPortAPI, "/readjobstatus/", fmt.Sprint(runData.JobID)}, "")
+	// Wait til the job is finished, the status is tracked as configured in the clientconfig.json
+	tracker := client.NewJobTracker(cd, transport)
+	jobWaitPoll := time.Duration(*cd.Flags.FlagjobWaitPoll) * time.Second
+	statusResp, err := client.WaitForJob(ctx, tracker, transport, *cd.Flags.FlagRequestor, runData.JobID, jobWaitPoll)
+	if err != nil {
+		return err
+	}
 
-	// Loop til the job report is finished and uploaded
-	for {
-		// Check if the job finished
-		finished, err := CheckJobStatus(readJobStatus)
-		if err != nil {
-			return err
-		}
-		// finished contains the job status (true = job finished, false = job still running)
-		// If the job is not already finished
-		if !finished {
-			// Sleep for the time thats configured in the clientconfig.json and than continue
-			time.Sleep(time.Duration(*cd.Flags.FlagjobWaitPoll) * time.Second)
-			continue
-		}
-		// If the job is finished
-		// Retrieve the response of the report and the URL of the uploaded report.
-		respBodyBytes, statusResp, err := RetrieveJobReport(transport, cd, parameter, runData.JobID)
-		if err != nil {
-			return err
-		}
+	// Retrieve the response of the report as bytes to upload it
+	respBodyBytes := new(bytes.Buffer)
+	if err := json.NewEncoder(respBodyBytes).Encode(statusResp); err != nil {
+		return fmt.Errorf("could not encode the jobReport: %w", err)
+	}
 
-		// Invoke function that uploads the test report to a S3 Bucket
-		// The function returns the name of the file that was uploaded to use it for the reportURL
-		reportURL, err := AddFileToS3(s, parameter, respBodyBytes.Bytes(), runData.JobID)
-		if err != nil {
-			return fmt.Errorf("could upload the jobReport to the S3 bucket: %w", err)
-		}
+	// Invoke function that uploads the test report to a S3 Bucket
+	// The function returns the name of the file that was uploaded to use it for the reportURL
+	reportURL, err := AddFileToS3(s, parameter, respBodyBytes.Bytes(), runData.JobID)
+	if err != nil {
+		return fmt.Errorf("could upload the jobReport to the S3 bucket: %w", err)
+	}
 
-		// Check if the job succeded
-		jobSuccess := CheckJobSuccess(statusResp.Data.Status.JobReport.RunReports)
-
-		// Adapt the Github Commit statuses and Slack Msg depending on the job success
-		// Creating the description for the report status and for the binary status
-		reportDesc := runData.JobName + ". Test-Report:"
-		binaryDesc := runData.JobName + ". Binary in S3 Bucket:"
-
-		// Parse the status resp for the binary url to update the binary status
-		// TODO: Find a way to differentiate multiple uploads in the report
-		regex := "https://" + parameter.S3Bucket + `[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-zA-Z0-9()]{1,6}\b([-a-zA-Z0-9()@:%_\+.~#?&//=]*)`
-		r, _ := regexp.Compile(regex)
-		binaryURL := r.FindString(respBodyBytes.String())
-		if binaryURL != "" {
-			// Update the Github status
-			err = UpdateGithubStatus(ctx, jobSuccess, binaryURL, binaryDesc, runData)
-			if err != nil {
-				return err
-			}
-		}
+	// If the job was cancelled, e.g. because it was superseded by a newer commit,
+	// the commit status was already set when cancelling it and must not be overwritten
+	if statusResp.Data.Status.State == string(job.EventJobCancelled) {
+		return nil
+	}
+
+	// Check if the job succeded
+	jobSuccess := client.JobSucceeded(statusResp.Data.Status)
+
+	// Adapt the Github Commit statuses and Slack Msg depending on the job success
+	// Creating the description for the report status and for the binary status
+	reportDesc := runData.JobName + ". Test-Report:"
+	binaryDesc := runData.JobName + ". Binary in S3 Bucket:"
 
+	// Parse the status resp for the binary url to update the binary status
+	// TODO: Find a way to differentiate multiple uploads in the report
+	regex := "https://" + parameter.S3Bucket + `[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-zA-Z0-9()]{1,6}\b([-a-zA-Z0-9()@:%_\+.~#?&//=]*)`
+	r, _ := regexp.Compile(regex)
+	binaryURL := r.FindString(respBodyBytes.String())
+	if binaryURL != "" {
 		// Update the Github status
-		err = UpdateGithubStatus(ctx, jobSuccess, reportURL, reportDesc, runData)
-		if err != nil {
-			return err
-		}
-		err = SendSlackMsg(jobSuccess, runData)
+		err = UpdateGithubStatus(ctx, jobSuccess, binaryURL, binaryDesc, runData)
 		if err != nil {
 			return err
 		}
+	}
 
-		return nil
+	// Update the Github status
+	err = UpdateGithubStatus(ctx, jobSuccess, reportURL, reportDesc, runData)
+	if err != nil {
+		return err
+	}
+	err = SendSlackMsg(ctx, jobSuccess, runData)
+	if err != nil {
+		return err
 	}
+
+	return nil
 }
 
 // AddFileToS3 will upload a single file to S3, it will require a pre-built aws session
@@ -145,46 +137,6 @@ func CreateAwsSession(parameter PushToS3) (*session.Session, error) {
 	return s, nil
 }
 
-// CheckJobStatus sends request to an API that returns if a job finished or not
-func CheckJobStatus(readJobStatus string) (bool, error) {
-	// API request
-	resp, err := http.Get(readJobStatus)
-	if err != nil {
-		return false, fmt.Errorf("could not post data to API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// If API request was not 200 (StatusOK)
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("the status code of the respone is != 200 (StatusOk)")
-	}
-
-	// Unmarshal the status of the job that was requested
-	var finished bool
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	json.Unmarshal(bodyBytes, &finished)
-	if err != nil {
-		return false, fmt.Errorf("error reading the HTTP response: %w", err)
-	}
-	// Return status
-	return finished, nil
-}
-
-// RetrieveJobReport retrieves the job report and returns it in 2 datatypes to use it in further proceeding
-func RetrieveJobReport(transport transport.Transport, cd client.ClientDescriptor, parameter PushToS3, jobID int) (
-	*bytes.Buffer, *api.StatusResponse, error) {
-	// Retrieve the jobReport
-	statusResp, err := transport.Status(context.Background(), *cd.Flags.FlagRequestor, types.JobID(jobID))
-	if err != nil {
-		return nil, nil, fmt.Errorf("could not retrieve the jobReport from the server: %w", err)
-	}
-	// Decoding
-	respBodyBytes := new(bytes.Buffer)
-	json.NewEncoder(respBodyBytes).Encode(statusResp)
-	// Return data as bytes.Buffer and api.StatusResponse
-	return respBodyBytes, statusResp, nil
-}
-
 // CheckJobSuccess parses the job report if the job was successful or not
 func CheckJobSuccess(jobStatus [][]*job.Report) bool {
 	// Go through all final reports
@@ -201,41 +153,42 @@ func CheckJobSuccess(jobStatus [][]*job.Report) bool {
 	return jobSuccess
 }
 
-// UpdateGithubStatus updates different Github statuses depending on the success of the job
+// UpdateGithubStatus updates the commit status of the job on its forge depending on the success of the job
 func UpdateGithubStatus(ctx context.Context, jobSuccess bool, dataURL string, statusDesc string,
 	runData client.RunData) error {
 
-	var Github = clientapi.GithubAPI{}
+	status := clientapi.Status{Owner: runData.RepoOwner, Repo: runData.RepoName, SHA: runData.JobSHA,
+		TargetURL: dataURL, Context: statusDesc}
 
 	// If the job was successful
 	if !jobSuccess {
-		// Update the github status
-		err := Github.EditGithubStatus(ctx, "error", dataURL, statusDesc, runData.JobSHA)
+		// Update the commit status
+		status.State = clientapi.StateError
+		err := clientapi.ActiveProviders().ReportStatus(ctx, runData.Forge, status)
 		if err != nil {
-			return fmt.Errorf("githubStatus could not be edited to status 'error': %w", err)
+			return fmt.Errorf("commit status could not be edited to status 'error': %w", err)
 		}
 
 		// If the job errors
 	} else {
-		// Update the github status
-		err := Github.EditGithubStatus(ctx, "success", dataURL, statusDesc, runData.JobSHA)
+		// Update the commit status
+		status.State = clientapi.StateSuccess
+		err := clientapi.ActiveProviders().ReportStatus(ctx, runData.Forge, status)
 		if err != nil {
-			return fmt.Errorf("githubStatus could not be edited to status 'success': %w", err)
+			return fmt.Errorf("commit status could not be edited to status 'success': %w", err)
 		}
 	}
 	return nil
 }
 
-// SendSlackMsg sends a msg to slack depending on the success of the job
-func SendSlackMsg(jobSuccess bool, runData client.RunData) error {
-
-	var Slack = clientapi.SlackAPI{}
+// SendSlackMsg sends a msg to the active notifiers, e.g. slack, depending on the success of the job
+func SendSlackMsg(ctx context.Context, jobSuccess bool, runData client.RunData) error {
 
 	// If the job was successful
 	if !jobSuccess {
 		// Create a slack msg and than post it
 		msg := strings.Join([]string{"Something goes wrong in the test with the jobName '", runData.JobName, "' and the jobID '", strconv.Itoa(runData.JobID), "'. Commit: '", runData.JobSHA, "'."}, "")
-		err := Slack.MsgToSlack(msg)
+		err := clientapi.ActiveProviders().Notify(ctx, msg)
 		if err != nil {
 			return fmt.Errorf("error could not posted to slack: %w", err)
 		}
@@ -244,7 +197,7 @@ func SendSlackMsg(jobSuccess bool, runData client.RunData) error {
 	} else {
 		// Create a slack msg and than post it
 		msg := strings.Join([]string{"The test with the jobName '", runData.JobName, "' and the jobID '", strconv.Itoa(runData.JobID), "' was successful. Commit: '", runData.JobSHA, "'."}, "")
-		err := Slack.MsgToSlack(msg)
+		err := clientapi.ActiveProviders().Notify(ctx, msg)
 		if err != nil {
 			return fmt.Errorf("success could not posted to slack: %w", err)
 		}